@@ -0,0 +1,144 @@
+// atomic_write.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/spf13/afero"
+)
+
+// defaultKeepRotations is how many historical "<path>.old[.N]" snapshots are
+// kept by atomicWriteFile when a caller doesn't configure otherwise.
+const defaultKeepRotations = 1
+
+// atomicWriteFile writes through write to a sibling "<path>.tmp", fsyncs it,
+// rotates up to keepRotations previous snapshots of path out of the way
+// ("<path>.old", "<path>.old.2", ...), then renames the tmp file into
+// path's place. This means a process killed mid-write never leaves path
+// truncated or empty - the previous run's output survives under
+// "<path>.old" until the new write has fully landed. If verify is true, path
+// is re-opened and checksummed against what was written before
+// atomicWriteFile returns successfully.
+func atomicWriteFile(fs afero.Fs, path string, keepRotations int, verify bool, write func(io.Writer) error) error {
+	tmpPath := path + ".tmp"
+
+	tmpFile, err := fs.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("error creating temp file %s: %w", tmpPath, err)
+	}
+
+	var dst io.Writer = tmpFile
+	var hasher hash.Hash
+	if verify {
+		hasher = sha512.New()
+		dst = io.MultiWriter(tmpFile, hasher)
+	}
+
+	if err := write(dst); err != nil {
+		tmpFile.Close()
+		fs.Remove(tmpPath)
+		return fmt.Errorf("error writing temp file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		fs.Remove(tmpPath)
+		return fmt.Errorf("error syncing temp file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		fs.Remove(tmpPath)
+		return fmt.Errorf("error closing temp file %s: %w", tmpPath, err)
+	}
+
+	if err := rotateOldSnapshots(fs, path, keepRotations); err != nil {
+		return err
+	}
+
+	if err := fs.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %w", tmpPath, path, err)
+	}
+
+	if verify {
+		if err := verifyWrittenFile(fs, path, hasher.Sum(nil)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateOldSnapshots shifts path's existing ".old"/".old.N" snapshots up by
+// one slot, dropping whatever would fall past keep, then moves the current
+// path (if any) into the freed ".old" slot so atomicWriteFile's rename never
+// overwrites the previous run's output outright.
+func rotateOldSnapshots(fs afero.Fs, path string, keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+
+	oldest := rotationPath(path, keep)
+	if exists, err := afero.Exists(fs, oldest); err != nil {
+		return fmt.Errorf("error checking %s: %w", oldest, err)
+	} else if exists {
+		if err := fs.Remove(oldest); err != nil {
+			return fmt.Errorf("error removing %s: %w", oldest, err)
+		}
+	}
+
+	for n := keep; n >= 1; n-- {
+		src := rotationPath(path, n-1)
+		dst := rotationPath(path, n)
+		exists, err := afero.Exists(fs, src)
+		if err != nil {
+			return fmt.Errorf("error checking %s: %w", src, err)
+		}
+		if !exists {
+			continue
+		}
+		if err := fs.Rename(src, dst); err != nil {
+			return fmt.Errorf("error rotating %s to %s: %w", src, dst, err)
+		}
+	}
+
+	return nil
+}
+
+// rotationPath returns path's Nth historical snapshot name: N=0 is path
+// itself (the rotation source), N=1 is "<path>.old", N>=2 is
+// "<path>.old.N".
+func rotationPath(path string, n int) string {
+	switch {
+	case n == 0:
+		return path
+	case n == 1:
+		return path + ".old"
+	default:
+		return fmt.Sprintf("%s.old.%d", path, n)
+	}
+}
+
+// verifyWrittenFile re-reads path and confirms its SHA-512 checksum matches
+// want, catching a rename onto a filesystem that silently truncated or
+// corrupted the write.
+func verifyWrittenFile(fs afero.Fs, path string, want []byte) error {
+	f, err := fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("error reopening %s for verification: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("error reading %s for verification: %w", path, err)
+	}
+
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("verification failed for %s: checksum mismatch after write", path)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicWriteFileRotatesPreviousOutput(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/root/fav.log"
+
+	require.NoError(t, afero.WriteFile(fs, path, []byte("first run"), 0644))
+
+	err := atomicWriteFile(fs, path, 1, false, func(w io.Writer) error {
+		_, err := io.WriteString(w, "second run")
+		return err
+	})
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, "second run", string(content))
+
+	oldContent, err := afero.ReadFile(fs, path+".old")
+	require.NoError(t, err)
+	assert.Equal(t, "first run", string(oldContent))
+}
+
+func TestAtomicWriteFileKeepsMultipleRotations(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/root/fav.log"
+
+	require.NoError(t, afero.WriteFile(fs, path, []byte("run1"), 0644))
+	require.NoError(t, atomicWriteFile(fs, path, 2, false, func(w io.Writer) error {
+		_, err := io.WriteString(w, "run2")
+		return err
+	}))
+	require.NoError(t, atomicWriteFile(fs, path, 2, false, func(w io.Writer) error {
+		_, err := io.WriteString(w, "run3")
+		return err
+	}))
+
+	content, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, "run3", string(content))
+
+	old1, err := afero.ReadFile(fs, path+".old")
+	require.NoError(t, err)
+	assert.Equal(t, "run2", string(old1))
+
+	old2, err := afero.ReadFile(fs, path+".old.2")
+	require.NoError(t, err)
+	assert.Equal(t, "run1", string(old2))
+}
+
+func TestAtomicWriteFileFailureMidWriteKeepsPreviousOutputIntact(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/root/fav.log"
+
+	require.NoError(t, afero.WriteFile(fs, path, []byte("previous output"), 0644))
+
+	writeErr := errors.New("simulated write failure")
+	err := atomicWriteFile(fs, path, 1, false, func(w io.Writer) error {
+		io.WriteString(w, "partial data")
+		return writeErr
+	})
+	require.Error(t, err)
+
+	content, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, "previous output", string(content), "a failed write must not disturb the previous output")
+
+	exists, err := afero.Exists(fs, path+".old")
+	require.NoError(t, err)
+	assert.False(t, exists, "no rotation should happen when the write itself fails")
+
+	tmpExists, err := afero.Exists(fs, path+".tmp")
+	require.NoError(t, err)
+	assert.False(t, tmpExists, "the failed tmp file should be cleaned up")
+}
+
+func TestAtomicWriteFileVerifyAfterWriteAcceptsIntactWrite(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/root/fav.log"
+
+	err := atomicWriteFile(fs, path, 1, true, func(w io.Writer) error {
+		_, err := io.WriteString(w, "consistent content")
+		return err
+	})
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, "consistent content", string(content))
+}
+
+func TestFileProcessorSaveToFileRotatesPreviousRun(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	rootDir := "/testroot"
+	require.NoError(t, fs.MkdirAll(rootDir, 0755))
+
+	outputPath := filepath.Join(rootDir, "fav.log")
+	require.NoError(t, afero.WriteFile(fs, outputPath, []byte("stale previous run"), 0644))
+
+	require.NoError(t, fp.saveToFile(rootDir, "fav.log", false))
+
+	_, err := afero.ReadFile(fs, outputPath)
+	require.NoError(t, err)
+
+	oldContent, err := afero.ReadFile(fs, outputPath+".old")
+	require.NoError(t, err)
+	assert.Equal(t, "stale previous run", string(oldContent))
+}
+
+func TestRotationPath(t *testing.T) {
+	assert.Equal(t, "/root/fav.log", rotationPath("/root/fav.log", 0))
+	assert.Equal(t, "/root/fav.log.old", rotationPath("/root/fav.log", 1))
+	assert.Equal(t, "/root/fav.log.old.3", rotationPath("/root/fav.log", 3))
+}
+
+func TestFileProcessorAtomicWriteHonorsOptions(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fp := CreateFileProcessor(nil, nil, nil, WithFilesystem(fs), WithKeepRotations(1), WithVerifyAfterWrite(true))
+
+	path := "/root/out.txt"
+	require.NoError(t, afero.WriteFile(fs, path, []byte("old"), 0644))
+
+	err := fp.atomicWrite(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	oldContent, err := afero.ReadFile(fs, path+".old")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(oldContent))
+}
@@ -0,0 +1,204 @@
+// bktree.go
+package main
+
+import "math"
+
+// SimilarityIndex narrows the O(N^2) space of filename pairs down to those
+// worth scoring exactly with calculateSimilarity. CandidatePairs receives
+// the already-normalized names (see normalizedBaseName) and the minScore
+// threshold the caller intends to filter on, and returns (i, j) index pairs
+// with i < j.
+type SimilarityIndex interface {
+	CandidatePairs(names []string, minScore float64) [][2]int
+}
+
+// bruteforceIndex is the original O(N^2) behavior: every pair is a
+// candidate. Useful as a correctness baseline and for small inputs where
+// building an index costs more than it saves.
+type bruteforceIndex struct{}
+
+func (bruteforceIndex) CandidatePairs(names []string, _ float64) [][2]int {
+	var pairs [][2]int
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	return pairs
+}
+
+// bkTreeIndex narrows candidates using a BK-tree keyed on Levenshtein
+// distance, per-name threshold queries derived from minScore.
+type bkTreeIndex struct{}
+
+func (bkTreeIndex) CandidatePairs(names []string, minScore float64) [][2]int {
+	tree := newBKTree()
+	for i, name := range names {
+		tree.Insert(name, i)
+	}
+
+	seen := make(map[[2]int]struct{})
+	var pairs [][2]int
+	for i, name := range names {
+		k := maxDistanceForScore(name, minScore)
+		for _, j := range tree.Query(name, k) {
+			if j == i {
+				continue
+			}
+			// The BK-tree already bounds this by exact distance, but
+			// re-verify with bandedLevenshtein (length-diff prefilter plus
+			// early row-min exit) before committing to the pair - cheaper
+			// than trusting a tree that may have been built concurrently
+			// with mutation in a future caller.
+			if bandedLevenshtein(name, names[j], k) > k {
+				continue
+			}
+			pair := [2]int{i, j}
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if _, ok := seen[pair]; ok {
+				continue
+			}
+			seen[pair] = struct{}{}
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// maxDistanceForScore derives the maximum Levenshtein distance k that still
+// satisfies minScore for a name of this length: score = 1 - d/len(name).
+func maxDistanceForScore(name string, minScore float64) int {
+	length := len([]rune(name))
+	if length == 0 {
+		return 0
+	}
+	k := int(math.Floor((1 - minScore) * float64(length)))
+	if k < 0 {
+		k = 0
+	}
+	return k
+}
+
+// bkTreeNode is a single node of the BK-tree: a name plus children bucketed
+// by the integer Levenshtein distance from this node's name to the child's.
+type bkTreeNode struct {
+	name     string
+	indices  []int // indices into the original names slice sharing this exact name
+	children map[int]*bkTreeNode
+}
+
+type bkTree struct {
+	root *bkTreeNode
+}
+
+func newBKTree() *bkTree {
+	return &bkTree{}
+}
+
+// Insert adds name (with its original index) to the tree. Insertion walks
+// from the root, computing the distance to the current node and descending
+// into the child bucket labeled by that distance, creating it if absent -
+// O(log N) average for well-distributed inputs.
+func (t *bkTree) Insert(name string, index int) {
+	if t.root == nil {
+		t.root = &bkTreeNode{name: name, indices: []int{index}, children: make(map[int]*bkTreeNode)}
+		return
+	}
+
+	node := t.root
+	for {
+		d := levenshteinDistance(node.name, name)
+		if d == 0 {
+			node.indices = append(node.indices, index)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			node.children[d] = &bkTreeNode{name: name, indices: []int{index}, children: make(map[int]*bkTreeNode)}
+			return
+		}
+		node = child
+	}
+}
+
+// Query returns the indices of every name within distance k of q, visiting
+// only the children whose edge label falls in [d-k, d+k] where d is the
+// distance from q to the current node.
+func (t *bkTree) Query(q string, k int) []int {
+	if t.root == nil {
+		return nil
+	}
+
+	var results []int
+	var visit func(node *bkTreeNode)
+	visit = func(node *bkTreeNode) {
+		// The exact distance is needed regardless of the length prefilter,
+		// since children are keyed by it; bandedLevenshtein only saves work
+		// when the length gap already rules the node itself out.
+		d := levenshteinDistance(node.name, q)
+		if d <= k {
+			results = append(results, node.indices...)
+		}
+
+		for label, child := range node.children {
+			if label >= d-k && label <= d+k {
+				visit(child)
+			}
+		}
+	}
+	visit(t.root)
+	return results
+}
+
+func lengthDiffExceeds(a, b string, k int) bool {
+	la, lb := len([]rune(a)), len([]rune(b))
+	diff := la - lb
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff > k
+}
+
+// bandedLevenshtein computes the Levenshtein distance between a and b, but
+// bails out early (returning k+1) as soon as every entry in the current row
+// exceeds k, since no alignment through that row can yield a final distance
+// ≤ k. It also skips the computation entirely when the length difference
+// alone already rules out a match.
+func bandedLevenshtein(a, b string, k int) int {
+	if lengthDiffExceeds(a, b, k) {
+		return k + 1
+	}
+
+	s1 := []rune(a)
+	s2 := []rune(b)
+	len1, len2 := len(s1), len(s2)
+
+	prev := make([]int, len2+1)
+	curr := make([]int, len2+1)
+	for j := 0; j <= len2; j++ {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len1; i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len2; j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > k {
+			return k + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len2]
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandedLevenshtein(t *testing.T) {
+	testCases := []struct {
+		name     string
+		str1     string
+		str2     string
+		k        int
+		expected int // capped at k+1 when the true distance exceeds k
+	}{
+		{"identical", "hello", "hello", 2, 0},
+		{"one edit within budget", "hello", "hallo", 2, 1},
+		{"exceeds budget", "hello", "world", 1, 2},
+		{"length gap exceeds budget", "hi", "hello world", 2, 3},
+		{"unicode within budget", "你好", "你们好", 2, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := bandedLevenshtein(tc.str1, tc.str2, tc.k)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestBKTreeInsertAndQuery(t *testing.T) {
+	tree := newBKTree()
+	names := []string{"similar_name_1", "similar_name_2", "totally_different", "你好世界", "你好世间"}
+	for i, name := range names {
+		tree.Insert(name, i)
+	}
+
+	results := tree.Query("similar_name_1", 2)
+	assert.Contains(t, results, 0)
+	assert.Contains(t, results, 1)
+	assert.NotContains(t, results, 2)
+
+	cjkResults := tree.Query("你好世界", 1)
+	assert.Contains(t, cjkResults, 3)
+	assert.Contains(t, cjkResults, 4)
+}
+
+func TestBKTreeIndexCandidatePairs(t *testing.T) {
+	names := []string{"similar_name_1", "similar_name_2", "totally_different"}
+	pairs := bkTreeIndex{}.CandidatePairs(names, 0.6)
+
+	require.Contains(t, pairs, [2]int{0, 1})
+	for _, pair := range pairs {
+		assert.NotEqual(t, [2]int{0, 2}, pair)
+		assert.NotEqual(t, [2]int{1, 2}, pair)
+	}
+}
+
+func TestBruteforceIndexCandidatePairsIsAllPairs(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	pairs := bruteforceIndex{}.CandidatePairs(names, 0.6)
+	assert.ElementsMatch(t, [][2]int{{0, 1}, {0, 2}, {1, 2}}, pairs)
+}
+
+func TestMaxDistanceForScore(t *testing.T) {
+	assert.Equal(t, 4, maxDistanceForScore("similar_name_1", 0.7))
+	assert.Equal(t, 0, maxDistanceForScore("", 0.5))
+}
+
+func TestCloseFileFinderSelectableSimilarityIndex(t *testing.T) {
+	cf := NewCloseFileFinder("/unused", WithSimilarityIndex(bruteforceIndex{}))
+	names := []string{"a.txt", "b.txt"}
+	pairs := cf.candidatePairs(names)
+	assert.ElementsMatch(t, [][2]int{{0, 1}}, pairs)
+}
@@ -0,0 +1,155 @@
+// checkpoint.go
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis key prefixes for resumable-scan state. Kept separate from
+// redis_client.go's keyPrefix* constants because they're namespaced by
+// rootDir/runID rather than by hashedKey.
+const (
+	keyPrefixScanCheckpoint = "scan:checkpoint:"
+	keyPrefixScanRunID      = "scan:runID:"
+	keyPrefixScanProcessed  = "scan:processed:"
+)
+
+// Checkpoint records how far a walkFiles run over a given rootDir has
+// progressed: RunID identifies the logical scan (monotonically increasing
+// per rootDir, reused across resumes of the same interrupted scan) and
+// LastPath is the lexicographically greatest relative path known to have
+// finished processing.
+type Checkpoint struct {
+	RunID    int64
+	LastPath string
+}
+
+func getScanCheckpointKey(rootDir string) string {
+	return keyPrefixScanCheckpoint + rootDir
+}
+
+func getScanRunIDKey(rootDir string) string {
+	return keyPrefixScanRunID + rootDir
+}
+
+func getScanProcessedKey(runID int64) string {
+	return fmt.Sprintf("%s%d", keyPrefixScanProcessed, runID)
+}
+
+// NextRunID returns a new monotonically increasing run ID for rootDir,
+// backed by a Redis INCR so concurrent invocations against the same rootDir
+// never collide.
+func (fp *FileProcessor) NextRunID(rootDir string) (int64, error) {
+	runID, err := fp.Rdb.Incr(fp.Ctx, getScanRunIDKey(rootDir)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error incrementing run ID for %s: %w", rootDir, err)
+	}
+	return runID, nil
+}
+
+// LoadCheckpoint returns the Checkpoint previously flushed for rootDir by
+// SaveCheckpoint, or nil if none is stored (a fresh scan).
+func (fp *FileProcessor) LoadCheckpoint(rootDir string) (*Checkpoint, error) {
+	val, err := fp.Rdb.Get(fp.Ctx, getScanCheckpointKey(rootDir)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading checkpoint for %s: %w", rootDir, err)
+	}
+
+	runIDStr, lastPath, found := strings.Cut(val, "\x00")
+	if !found {
+		return nil, fmt.Errorf("malformed checkpoint value for %s: %q", rootDir, val)
+	}
+	runID, err := strconv.ParseInt(runIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed run ID in checkpoint for %s: %w", rootDir, err)
+	}
+
+	return &Checkpoint{RunID: runID, LastPath: lastPath}, nil
+}
+
+// StartOrResumeCheckpoint returns the Checkpoint to resume from if rootDir
+// has one saved, otherwise it mints a fresh RunID via NextRunID and returns
+// a Checkpoint with an empty LastPath.
+func (fp *FileProcessor) StartOrResumeCheckpoint(rootDir string) (*Checkpoint, error) {
+	cp, err := fp.LoadCheckpoint(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil {
+		return cp, nil
+	}
+
+	runID, err := fp.NextRunID(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{RunID: runID}, nil
+}
+
+// SaveCheckpoint flushes cp to scan:checkpoint:<rootDir> so a later run of
+// the same rootDir can resume from it via LoadCheckpoint.
+func (fp *FileProcessor) SaveCheckpoint(rootDir string, cp Checkpoint) error {
+	val := fmt.Sprintf("%d\x00%s", cp.RunID, cp.LastPath)
+	if err := fp.Rdb.Set(fp.Ctx, getScanCheckpointKey(rootDir), val, 0).Err(); err != nil {
+		return fmt.Errorf("error saving checkpoint for %s: %w", rootDir, err)
+	}
+	return nil
+}
+
+// ClearCheckpoint removes rootDir's checkpoint and processed set, which
+// main does once a scan finishes a full pass without being cancelled,
+// so the next invocation starts a genuinely fresh scan rather than resuming.
+func (fp *FileProcessor) ClearCheckpoint(rootDir string, runID int64) error {
+	if err := fp.Rdb.Del(fp.Ctx, getScanCheckpointKey(rootDir), getScanProcessedKey(runID)).Err(); err != nil {
+		return fmt.Errorf("error clearing checkpoint for %s: %w", rootDir, err)
+	}
+	return nil
+}
+
+// MarkProcessed records relativePath as done under runID's processed set.
+// Unlike the checkpoint's LastPath high-water mark, this is safe to consult
+// for any path regardless of completion order across concurrent workers.
+func (fp *FileProcessor) MarkProcessed(runID int64, relativePath string) error {
+	if err := fp.Rdb.SAdd(fp.Ctx, getScanProcessedKey(runID), relativePath).Err(); err != nil {
+		return fmt.Errorf("error marking %s processed for run %d: %w", relativePath, runID, err)
+	}
+	return nil
+}
+
+// IsProcessed reports whether relativePath is already recorded as done under
+// runID's processed set.
+func (fp *FileProcessor) IsProcessed(runID int64, relativePath string) (bool, error) {
+	done, err := fp.Rdb.SIsMember(fp.Ctx, getScanProcessedKey(runID), relativePath).Result()
+	if err != nil {
+		return false, fmt.Errorf("error checking processed state of %s for run %d: %w", relativePath, runID, err)
+	}
+	return done, nil
+}
+
+// ShouldSkipForResume reports whether walkFiles should skip relativePath
+// because a prior, interrupted run of cp.RunID already finished it: either
+// relativePath sorts at or before cp.LastPath when compared path component
+// by path component (the common case, since that is the order filepath.Walk
+// visits entries in: "a/b" before "a.txt"), or, for the rare
+// case of a path landing out of that order, it's already in the
+// processed:<runID> set. A nil cp (no checkpoint to resume from) never skips.
+func (fp *FileProcessor) ShouldSkipForResume(cp *Checkpoint, relativePath string) (bool, error) {
+	if cp == nil || cp.LastPath == "" {
+		return false, nil
+	}
+	// Mapping the separator to "\x00" makes a plain string comparison
+	// compare component by component.
+	sep := string(filepath.Separator)
+	if strings.ReplaceAll(relativePath, sep, "\x00") <= strings.ReplaceAll(cp.LastPath, sep, "\x00") {
+		return true, nil
+	}
+	return fp.IsProcessed(cp.RunID, relativePath)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_StartOrResumeCheckpoint_FreshScanHasEmptyLastPath(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	cp, err := fp.StartOrResumeCheckpoint("/root")
+	require.NoError(t, err)
+	assert.Empty(t, cp.LastPath)
+	assert.Greater(t, cp.RunID, int64(0))
+}
+
+func TestFileProcessor_SaveCheckpoint_StartOrResumeLoadsIt(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fp.SaveCheckpoint("/root", Checkpoint{RunID: 7, LastPath: "b/file.bin"}))
+
+	cp, err := fp.StartOrResumeCheckpoint("/root")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), cp.RunID)
+	assert.Equal(t, "b/file.bin", cp.LastPath)
+}
+
+func TestFileProcessor_NextRunID_Increments(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	first, err := fp.NextRunID("/root")
+	require.NoError(t, err)
+	second, err := fp.NextRunID("/root")
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+}
+
+func TestFileProcessor_ClearCheckpoint_RemovesCheckpointAndProcessedSet(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fp.SaveCheckpoint("/root", Checkpoint{RunID: 3, LastPath: "a.txt"}))
+	require.NoError(t, fp.MarkProcessed(3, "a.txt"))
+
+	require.NoError(t, fp.ClearCheckpoint("/root", 3))
+
+	cp, err := fp.LoadCheckpoint("/root")
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+
+	processed, err := fp.IsProcessed(3, "a.txt")
+	require.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestFileProcessor_ShouldSkipForResume(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	skip, err := fp.ShouldSkipForResume(nil, "a.txt")
+	require.NoError(t, err)
+	assert.False(t, skip, "no checkpoint means nothing is skipped")
+
+	cp := &Checkpoint{RunID: 1, LastPath: "m.txt"}
+
+	skip, err = fp.ShouldSkipForResume(cp, "a.txt")
+	require.NoError(t, err)
+	assert.True(t, skip, "a.txt sorts before the checkpoint's last path")
+
+	skip, err = fp.ShouldSkipForResume(cp, "z.txt")
+	require.NoError(t, err)
+	assert.False(t, skip, "z.txt sorts after the checkpoint and isn't in the processed set")
+
+	require.NoError(t, fp.MarkProcessed(1, "z.txt"))
+
+	skip, err = fp.ShouldSkipForResume(cp, "z.txt")
+	require.NoError(t, err)
+	assert.True(t, skip, "z.txt is now in the processed set despite sorting after the checkpoint")
+}
@@ -3,25 +3,101 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+
+	"github.com/spf13/afero"
 )
 
 type CloseFileFinder struct {
 	rootDir     string
 	workerCount int
 	minScore    float64
+	normalize   NormalizeOptions
+	fs          afero.Fs
+	index       SimilarityIndex
+	// keepRotations and verifyAfterWrite control how writeResults uses
+	// atomicWriteFile to write fav.log.close; see WithRotations and
+	// WithVerifyWrite.
+	keepRotations    int
+	verifyAfterWrite bool
 }
 
-func NewCloseFileFinder(rootDir string) *CloseFileFinder {
-	return &CloseFileFinder{
-		rootDir:     rootDir,
-		workerCount: runtime.NumCPU(),
-		minScore:    0.6, // 相似度阈值，可配置
+// NewCloseFileFinder creates a CloseFileFinder rooted at rootDir using the
+// real OS filesystem and the bktree SimilarityIndex. Pass options to
+// override the filesystem, normalization, or similarity index, e.g.
+// NewCloseFileFinder(dir, WithFs(afero.NewMemMapFs()), WithSimilarityIndex(bruteforceIndex{})).
+func NewCloseFileFinder(rootDir string, opts ...func(*CloseFileFinder)) *CloseFileFinder {
+	cf := &CloseFileFinder{
+		rootDir:       rootDir,
+		workerCount:   runtime.NumCPU(),
+		minScore:      0.6, // 相似度阈值，可配置
+		normalize:     DefaultNormalizeOptions(),
+		fs:            afero.NewOsFs(),
+		index:         bkTreeIndex{},
+		keepRotations: defaultKeepRotations,
+	}
+	for _, opt := range opts {
+		opt(cf)
 	}
+	return cf
+}
+
+// WithFs overrides the afero.Fs used to read fav.log and write results,
+// letting callers run against afero.NewMemMapFs() in tests or compose
+// CloseFileFinder with the rest of the pipeline over a single virtual root.
+func WithFs(fs afero.Fs) func(*CloseFileFinder) {
+	return func(cf *CloseFileFinder) { cf.fs = fs }
+}
+
+// WithNormalizeOptions overrides the NormalizeOptions used when building
+// shingles/keys for similarity matching, e.g. noise tokens loaded via
+// loadNoiseTokens from the same config-file convention exclude_patterns.txt
+// uses.
+func WithNormalizeOptions(normalize NormalizeOptions) func(*CloseFileFinder) {
+	return func(cf *CloseFileFinder) { cf.normalize = normalize }
+}
+
+// WithSimilarityIndex overrides the candidate-pair prefilter, e.g.
+// bruteforceIndex{} for a correctness baseline or minhashIndex{} for the
+// LSH-based prefilter.
+func WithSimilarityIndex(index SimilarityIndex) func(*CloseFileFinder) {
+	return func(cf *CloseFileFinder) { cf.index = index }
+}
+
+// WithRotations overrides how many historical "fav.log.close.old[.N]"
+// snapshots writeResults keeps around via atomicWriteFile.
+func WithRotations(n int) func(*CloseFileFinder) {
+	return func(cf *CloseFileFinder) { cf.keepRotations = n }
+}
+
+// WithVerifyWrite makes writeResults re-open and checksum fav.log.close
+// after renaming it into place.
+func WithVerifyWrite(verify bool) func(*CloseFileFinder) {
+	return func(cf *CloseFileFinder) { cf.verifyAfterWrite = verify }
+}
+
+// NewCloseFileFinderWithFs is NewCloseFileFinder with a caller-supplied
+// afero.Fs. Kept for callers that predate the functional-options form.
+func NewCloseFileFinderWithFs(rootDir string, fs afero.Fs) *CloseFileFinder {
+	return NewCloseFileFinder(rootDir, WithFs(fs))
+}
+
+// NewCloseFileFinderSandboxed wraps baseFs in an afero.BasePathFs rooted at
+// subtree, so fav.log/fav.log.close reads and writes can be confined to a
+// subtree of a larger filesystem without changing the process's working
+// directory.
+func NewCloseFileFinderSandboxed(baseFs afero.Fs, subtree string) *CloseFileFinder {
+	return NewCloseFileFinder(".", WithFs(afero.NewBasePathFs(baseFs, subtree)))
+}
+
+// NewCloseFileFinderWithOptions is NewCloseFileFinder with a caller-supplied
+// NormalizeOptions. Kept for callers that predate the functional-options form.
+func NewCloseFileFinderWithOptions(rootDir string, normalize NormalizeOptions) *CloseFileFinder {
+	return NewCloseFileFinder(rootDir, WithNormalizeOptions(normalize))
 }
 
 // 处理 fav.log 文件并生成 fav.log.close
@@ -42,7 +118,7 @@ func (cf *CloseFileFinder) ProcessCloseFiles() error {
 // 从 fav.log 读取文件信息
 func (cf *CloseFileFinder) readFavLog() ([]string, error) {
 	favLogPath := filepath.Join(cf.rootDir, "fav.log")
-	file, err := os.Open(favLogPath)
+	file, err := cf.fs.Open(favLogPath)
 	if err != nil {
 		return nil, err
 	}
@@ -72,26 +148,28 @@ type similarityResult struct {
 	score float64
 }
 
-// 查找相似文件
+// findCloseFiles finds pairs of similar filenames. Comparing every file
+// against every other file is O(n²) and becomes the bottleneck once fav.log
+// holds tens of thousands of entries, so candidate pairs are first narrowed
+// down by cf.index (a bkTreeIndex by default) and only those candidates are
+// scored with the exact Levenshtein-based similarity used before.
 func (cf *CloseFileFinder) findCloseFiles(files []string) []similarityResult {
+	candidatePairs, names := cf.candidatePairsAndNames(files)
+
 	var (
 		wg       sync.WaitGroup
 		mu       sync.Mutex
 		results  []similarityResult
-		taskChan = make(chan [2]int, len(files)*len(files)/2)
+		taskChan = make(chan [2]int, len(candidatePairs))
 	)
 
-	// 启动工作协程
 	for i := 0; i < cf.workerCount; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for task := range taskChan {
 				i, j := task[0], task[1]
-				score := calculateSimilarity(
-					filepath.Base(files[i]),
-					filepath.Base(files[j]),
-				)
+				score := scoreNormalizedNames(names[i], names[j])
 
 				if score >= cf.minScore {
 					mu.Lock()
@@ -106,11 +184,8 @@ func (cf *CloseFileFinder) findCloseFiles(files []string) []similarityResult {
 		}()
 	}
 
-	// 分发任务
-	for i := 0; i < len(files); i++ {
-		for j := i + 1; j < len(files); j++ {
-			taskChan <- [2]int{i, j}
-		}
+	for _, pair := range candidatePairs {
+		taskChan <- pair
 	}
 	close(taskChan)
 	wg.Wait()
@@ -118,16 +193,47 @@ func (cf *CloseFileFinder) findCloseFiles(files []string) []similarityResult {
 	return results
 }
 
+// candidatePairs returns the set of (i, j) index pairs, with i < j, worth
+// scoring exactly, as decided by cf.index over the normalized basenames.
+func (cf *CloseFileFinder) candidatePairs(files []string) [][2]int {
+	pairs, _ := cf.candidatePairsAndNames(files)
+	return pairs
+}
+
+// candidatePairsAndNames is candidatePairs, but also returns the normalized
+// basename it computed for each file. findCloseFiles' scoring loop reuses
+// these rather than re-normalizing (and rebuilding a diacritic
+// transform.Chain, see newDiacriticTransformer) per candidate pair.
+func (cf *CloseFileFinder) candidatePairsAndNames(files []string) ([][2]int, []string) {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = normalizedBaseName(file, cf.normalize)
+	}
+	return cf.index.CandidatePairs(names, cf.minScore), names
+}
+
 // 计算两个文件名的相似度
 func calculateSimilarity(name1, name2 string) float64 {
 	// 移除扩展名
 	name1 = strings.TrimSuffix(name1, filepath.Ext(name1))
 	name2 = strings.TrimSuffix(name2, filepath.Ext(name2))
 
-	// 转换为小写进行比较
-	name1 = strings.ToLower(name1)
-	name2 = strings.ToLower(name2)
+	// Unicode-normalize both names (NFC + case fold + diacritic strip) so
+	// accented and CJK filenames that differ only cosmetically compare as
+	// near-identical, mirroring Hugo's MakePath folding.
+	opts := DefaultNormalizeOptions()
+	name1 = NormalizeFileName(name1, opts)
+	name2 = NormalizeFileName(name2, opts)
+
+	return scoreNormalizedNames(name1, name2)
+}
 
+// scoreNormalizedNames scores two filenames that have already been run
+// through NormalizeFileName (e.g. via normalizedBaseName), as
+// candidatePairsAndNames does once per file up front - letting
+// findCloseFiles' hot path avoid normalizing the same name again for every
+// candidate pair it appears in.
+func scoreNormalizedNames(name1, name2 string) float64 {
 	// 使用 Levenshtein 距离计算相似度
 	distance := levenshteinDistance(name1, name2)
 	maxLen := float64(max(len(name1), len(name2)))
@@ -142,26 +248,23 @@ func calculateSimilarity(name1, name2 string) float64 {
 // 写入结果到 fav.log.close
 func (cf *CloseFileFinder) writeResults(results []similarityResult) error {
 	outputPath := filepath.Join(cf.rootDir, "fav.log.close")
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	for _, result := range results {
-		_, err := fmt.Fprintf(writer,
-			"相似度: %.2f\n文件1: %s\n文件2: %s\n\n",
-			result.score,
-			result.file1,
-			result.file2,
-		)
-		if err != nil {
-			return err
+	return atomicWriteFile(cf.fs, outputPath, cf.keepRotations, cf.verifyAfterWrite, func(file io.Writer) error {
+		writer := bufio.NewWriter(file)
+		for _, result := range results {
+			_, err := fmt.Fprintf(writer,
+				"相似度: %.2f\n文件1: %s\n文件2: %s\n\n",
+				result.score,
+				result.file1,
+				result.file2,
+			)
+			if err != nil {
+				return err
+			}
 		}
-	}
 
-	return writer.Flush()
+		return writer.Flush()
+	})
 }
 
 func max(a, b int) int {
@@ -8,14 +8,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// 创建测试辅助函数
-func setupCloseFileTest(t *testing.T) (string, *CloseFileFinder, func()) {
-	// 创建临时目录
-	tempDir, err := os.MkdirTemp("", "closefiles_test")
+// 创建测试辅助函数。使用 afero.NewMemMapFs 以便在内存中运行，
+// 与 TestFileProcessorIntegration 保持一致的风格。
+func setupCloseFileTest(t *testing.T) (string, *CloseFileFinder, afero.Fs, func()) {
+	fs := afero.NewMemMapFs()
+	tempDir, err := afero.TempDir(fs, "", "closefiles_test")
 	require.NoError(t, err)
 
 	// 创建测试用的 fav.log 文件
@@ -25,23 +27,18 @@ func setupCloseFileTest(t *testing.T) (string, *CloseFileFinder, func()) {
 400,"similar_name_2.mp4"
 500,"totally_different.txt"
 `
-	err = os.WriteFile(filepath.Join(tempDir, "fav.log"), []byte(favLog), 0644)
+	err = afero.WriteFile(fs, filepath.Join(tempDir, "fav.log"), []byte(favLog), 0644)
 	require.NoError(t, err)
 
 	// 创建 CloseFileFinder 实例
-	finder := NewCloseFileFinder(tempDir)
-
-	// 返回清理函数
-	cleanup := func() {
-		os.RemoveAll(tempDir)
-	}
+	finder := NewCloseFileFinderWithFs(tempDir, fs)
 
-	return tempDir, finder, cleanup
+	return tempDir, finder, fs, func() {}
 }
 
 // 使用测试辅助函数重写测试用例
 func TestCloseFileFinder(t *testing.T) {
-	tempDir, finder, cleanup := setupCloseFileTest(t)
+	tempDir, finder, fs, cleanup := setupCloseFileTest(t)
 	defer cleanup()
 
 	// 测试处理文件
@@ -50,11 +47,11 @@ func TestCloseFileFinder(t *testing.T) {
 
 	// 验证输出文件存在
 	outputPath := filepath.Join(tempDir, "fav.log.close")
-	_, err = os.Stat(outputPath)
+	_, err = fs.Stat(outputPath)
 	assert.NoError(t, err)
 
 	// 读取并验证输出内容
-	content, err := os.ReadFile(outputPath)
+	content, err := afero.ReadFile(fs, outputPath)
 	require.NoError(t, err)
 
 	// 验证相似文件被正确识别
@@ -150,9 +147,9 @@ func TestCalculateSimilarityEdgeCases(t *testing.T) {
 }
 
 func TestCloseFileFinderConcurrency(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "closefiles_concurrent_test")
+	fs := afero.NewMemMapFs()
+	tempDir, err := afero.TempDir(fs, "", "closefiles_concurrent_test")
 	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
 
 	// 创建大量测试文件名
 	var fileNames []string
@@ -165,11 +162,11 @@ func TestCloseFileFinderConcurrency(t *testing.T) {
 	for _, name := range fileNames {
 		content.WriteString(fmt.Sprintf("100,\"%s\"\n", name))
 	}
-	err = os.WriteFile(filepath.Join(tempDir, "fav.log"), []byte(content.String()), 0644)
+	err = afero.WriteFile(fs, filepath.Join(tempDir, "fav.log"), []byte(content.String()), 0644)
 	require.NoError(t, err)
 
 	// 测试并发处理
-	finder := NewCloseFileFinder(tempDir)
+	finder := NewCloseFileFinderWithFs(tempDir, fs)
 	start := time.Now()
 	err = finder.ProcessCloseFiles()
 	duration := time.Since(start)
@@ -178,7 +175,7 @@ func TestCloseFileFinderConcurrency(t *testing.T) {
 	assert.Less(t, duration, 9*time.Second, "并发处理应该在合理时间内完成")
 
 	// 验证输出文件
-	outputContent, err := os.ReadFile(filepath.Join(tempDir, "fav.log.close"))
+	outputContent, err := afero.ReadFile(fs, filepath.Join(tempDir, "fav.log.close"))
 	require.NoError(t, err)
 	assert.NotEmpty(t, outputContent)
 }
@@ -190,6 +187,7 @@ func TestCloseFileFinderErrors(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	t.Run("不存在的fav.log文件", func(t *testing.T) {
+		// 使用真实操作系统文件系统，以验证底层 os 的错误信息。
 		finder := NewCloseFileFinder(tempDir)
 		err := finder.ProcessCloseFiles()
 		assert.Error(t, err)
@@ -208,13 +206,25 @@ func TestCloseFileFinderErrors(t *testing.T) {
 	})
 }
 
+func TestNewCloseFileFinderSandboxed(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/data/sub", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/data/sub/fav.log", []byte(`100,"a.txt"`+"\n"), 0644))
+
+	finder := NewCloseFileFinderSandboxed(fs, "/data/sub")
+	require.NoError(t, finder.ProcessCloseFiles())
+
+	_, err := afero.Exists(fs, "/data/sub/fav.log.close")
+	require.NoError(t, err)
+}
+
 // 如果需要测试其他场景，可以继续使用相同的辅助函数
 func TestCloseFileFinderWithEmptyFile(t *testing.T) {
-	tempDir, finder, cleanup := setupCloseFileTest(t)
+	tempDir, finder, fs, cleanup := setupCloseFileTest(t)
 	defer cleanup()
 
 	// 清空 fav.log 文件
-	err := os.WriteFile(filepath.Join(tempDir, "fav.log"), []byte(""), 0644)
+	err := afero.WriteFile(fs, filepath.Join(tempDir, "fav.log"), []byte(""), 0644)
 	require.NoError(t, err)
 
 	// 测试处理空文件
@@ -223,7 +233,7 @@ func TestCloseFileFinderWithEmptyFile(t *testing.T) {
 
 	// 验证输出文件存在但为空
 	outputPath := filepath.Join(tempDir, "fav.log.close")
-	content, err := os.ReadFile(outputPath)
+	content, err := afero.ReadFile(fs, outputPath)
 	require.NoError(t, err)
 	assert.Empty(t, string(content))
 }
@@ -0,0 +1,399 @@
+// content_cdc.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixCDCChunks      = "hashedKeyToCDCChunks:"
+	keyPrefixCDCChunkToPath = "cdcChunkToPaths:"
+
+	// defaultCDCAvgChunkSize, defaultCDCMinChunkSize, and
+	// defaultCDCMaxChunkSize are the content-defined-chunking target sizes
+	// used when a FileProcessor doesn't override them: a 1 MiB average chunk,
+	// never smaller than 256 KiB nor larger than 4 MiB. Unlike the fixed-size
+	// pieces in content_pieces.go, CDC boundaries are determined by the
+	// content itself, so a byte insertion or deletion only shifts the one
+	// chunk it falls in - every other chunk hash stays identical.
+	defaultCDCAvgChunkSize = 1 * 1024 * 1024
+	defaultCDCMinChunkSize = 256 * 1024
+	defaultCDCMaxChunkSize = 4 * 1024 * 1024
+
+	// defaultCDCSimilarityThreshold is the minimum Jaccard similarity of two
+	// files' CDC chunk-hash sets for FindSimilarFiles to report them.
+	defaultCDCSimilarityThreshold = 0.5
+)
+
+// cdcGearTable is a fixed, deterministically-seeded table of per-byte
+// multipliers for the gear-hash rolling hash cdcChunkBoundary uses (the same
+// technique FastCDC is built on). It's computed once via splitmix64 rather
+// than math/rand so chunk boundaries - and therefore chunk hashes - are
+// stable across runs and processes without depending on a seeded PRNG.
+var cdcGearTable [256]uint64
+
+func init() {
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range cdcGearTable {
+		seed += 0x9E3779B97F4A7C15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		z ^= z >> 31
+		cdcGearTable[i] = z
+	}
+}
+
+// CDCChunks is the gob-encoded value stored under
+// hashedKeyToCDCChunks:<hashedKey>: the ordered SHA-256 hash of each
+// content-defined chunk.
+type CDCChunks struct {
+	Hashes []string
+}
+
+func getCDCChunksKey(hashedKey string) string {
+	return keyPrefixCDCChunks + hashedKey
+}
+
+func getCDCChunkToPathSetKey(chunkHash string) string {
+	return keyPrefixCDCChunkToPath + chunkHash
+}
+
+// cdcMask returns the gear-hash mask that yields an average chunk size of
+// avgSize: avgSize rounded down to the nearest power of two, minus one, so a
+// chunk boundary (hash&mask == 0) occurs on average once every avgSize
+// bytes.
+func cdcMask(avgSize int) uint64 {
+	if avgSize < 2 {
+		return 0
+	}
+	maskBits := bits.Len(uint(avgSize)) - 1
+	return uint64(1)<<uint(maskBits) - 1
+}
+
+// cdcChunk is one content-defined chunk's SHA-256 hash, byte offset, and
+// size within the file it was split from.
+type cdcChunk struct {
+	Hash   string
+	Offset int64
+	Size   int64
+}
+
+// splitCDCChunks splits fullPath into content-defined chunks using a
+// gear-hash rolling hash: a boundary falls wherever the hash of the trailing
+// window satisfies hash&mask == 0, clamped to [minSize, maxSize] so no chunk
+// is pathologically small or unbounded. It returns each chunk's hash, offset,
+// and size, in order. Non-positive sizes fall back to the package defaults.
+func (fp *FileProcessor) splitCDCChunks(fullPath string, avgSize, minSize, maxSize int) ([]cdcChunk, error) {
+	if avgSize <= 0 {
+		avgSize = defaultCDCAvgChunkSize
+	}
+	if minSize <= 0 {
+		minSize = defaultCDCMinChunkSize
+	}
+	if maxSize <= 0 {
+		maxSize = defaultCDCMaxChunkSize
+	}
+	mask := cdcMask(avgSize)
+
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file for CDC chunking: %w", err)
+	}
+	defer f.Close()
+
+	var chunks []cdcChunk
+	var current []byte
+	var hash uint64
+	var offset int64
+	buf := make([]byte, 64*1024)
+
+	flush := func() {
+		h := sha256.Sum256(current)
+		chunks = append(chunks, cdcChunk{
+			Hash:   hex.EncodeToString(h[:]),
+			Offset: offset,
+			Size:   int64(len(current)),
+		})
+		offset += int64(len(current))
+		current = current[:0]
+		hash = 0
+	}
+
+	for {
+		n, rerr := f.Read(buf)
+		for i := 0; i < n; i++ {
+			b := buf[i]
+			current = append(current, b)
+			hash = (hash << 1) + cdcGearTable[b]
+			if len(current) >= minSize && (len(current) >= maxSize || hash&mask == 0) {
+				flush()
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, fmt.Errorf("error reading %s for CDC chunking: %w", fullPath, rerr)
+		}
+	}
+	if len(current) > 0 {
+		flush()
+	}
+
+	return chunks, nil
+}
+
+// calculateCDCChunkHashes splits fullPath into content-defined chunks and
+// returns just the SHA-256 hash of each, in order; see splitCDCChunks for how
+// boundaries are chosen.
+func (fp *FileProcessor) calculateCDCChunkHashes(fullPath string, avgSize, minSize, maxSize int) ([]string, error) {
+	chunks, err := fp.splitCDCChunks(fullPath, avgSize, minSize, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(chunks))
+	for i, c := range chunks {
+		hashes[i] = c.Hash
+	}
+	return hashes, nil
+}
+
+// ProcessFileCDC computes fullPath's content-defined chunk hashes using
+// fp.CDCAvgChunkSize/CDCMinChunkSize/CDCMaxChunkSize (see WithCDCChunkSizes)
+// and stores them under hashedKeyToCDCChunks:<hashedKey>, adding fullPath to
+// each chunk's cdcChunkToPaths:<chunkHash> set so FindSimilarFiles can look
+// up candidates without scanning every file's chunk list. Like
+// ProcessFileContentSimilarity, it assumes ProcessFile has already run for
+// fullPath and populated hashedKeyToPath:<hashedKey> - FindSimilarFiles
+// resolves paths through that mapping rather than storing them twice.
+func (fp *FileProcessor) ProcessFileCDC(fullPath string) error {
+	hashes, err := fp.calculateCDCChunkHashes(fullPath, fp.CDCAvgChunkSize, fp.CDCMinChunkSize, fp.CDCMaxChunkSize)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(CDCChunks{Hashes: hashes}); err != nil {
+		return fmt.Errorf("error encoding CDC chunks for %s: %w", fullPath, err)
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+
+	pipe := fp.Rdb.Pipeline()
+	pipe.Set(fp.Ctx, getCDCChunksKey(hashedKey), buf.Bytes(), 0)
+	seen := make(map[string]struct{}, len(hashes))
+	for _, chunkHash := range hashes {
+		if _, dup := seen[chunkHash]; dup {
+			continue
+		}
+		seen[chunkHash] = struct{}{}
+		pipe.SAdd(fp.Ctx, getCDCChunkToPathSetKey(chunkHash), fullPath)
+	}
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return fmt.Errorf("error saving CDC chunks for %s: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// getCDCChunks reads back the CDCChunks stored for hashedKey.
+func (fp *FileProcessor) getCDCChunks(hashedKey string) (CDCChunks, error) {
+	var chunks CDCChunks
+	value, err := fp.Rdb.Get(fp.Ctx, getCDCChunksKey(hashedKey)).Bytes()
+	if err != nil {
+		return chunks, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&chunks); err != nil {
+		return chunks, fmt.Errorf("error decoding CDC chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// scanCDCChunks returns every path -> CDCChunks pair currently stored.
+func (fp *FileProcessor) scanCDCChunks() (map[string]CDCChunks, error) {
+	result := make(map[string]CDCChunks)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixCDCChunks+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixCDCChunks)
+
+		chunks, err := fp.getCDCChunks(hashedKey)
+		if err != nil {
+			continue
+		}
+
+		path, err := fp.Rdb.Get(fp.Ctx, getHashedKeyToPathKey(hashedKey)).Result()
+		if err != nil {
+			continue
+		}
+
+		result[path] = chunks
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning CDC chunks: %w", err)
+	}
+	return result, nil
+}
+
+// candidateCDCPaths returns the union of paths sharing at least one of
+// chunkHashes, read from the cdcChunkToPaths reverse index so FindSimilarFiles
+// never has to compare every pair of files directly.
+func (fp *FileProcessor) candidateCDCPaths(chunkHashes []string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, chunkHash := range chunkHashes {
+		members, err := fp.Rdb.SMembers(fp.Ctx, getCDCChunkToPathSetKey(chunkHash)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading CDC chunk bucket %s: %w", chunkHash, err)
+		}
+		for _, m := range members {
+			if _, ok := seen[m]; ok {
+				continue
+			}
+			seen[m] = struct{}{}
+			paths = append(paths, m)
+		}
+	}
+	return paths, nil
+}
+
+// CDCSimilarityPair is a near-duplicate match found by FindSimilarFiles.
+type CDCSimilarityPair struct {
+	Path1   string
+	Path2   string
+	Jaccard float64
+}
+
+// FindSimilarFiles scans every file with CDC chunks recorded and, for each
+// one, probes the reverse index for files sharing at least one chunk before
+// computing the exact Jaccard similarity of their chunk-hash sets, returning
+// pairs at or above threshold (<=0 uses defaultCDCSimilarityThreshold).
+//
+// Because CDC boundaries resync after an insertion or deletion, two files
+// that only differ by a re-muxed container header or a spliced-in segment
+// still share most of their chunks, where fixed-size piece hashing
+// (content_pieces.go) would see every piece after the edit point shifted and
+// miss the match entirely.
+func (fp *FileProcessor) FindSimilarFiles(threshold float64) ([]CDCSimilarityPair, error) {
+	if threshold <= 0 {
+		threshold = defaultCDCSimilarityThreshold
+	}
+
+	pathToChunks, err := fp.scanCDCChunks()
+	if err != nil {
+		return nil, err
+	}
+
+	pathToSet := make(map[string]map[string]struct{}, len(pathToChunks))
+	for path, chunks := range pathToChunks {
+		set := make(map[string]struct{}, len(chunks.Hashes))
+		for _, h := range chunks.Hashes {
+			set[h] = struct{}{}
+		}
+		pathToSet[path] = set
+	}
+
+	seen := make(map[[2]string]struct{})
+	var pairs []CDCSimilarityPair
+
+	for path, chunks := range pathToChunks {
+		candidates, err := fp.candidateCDCPaths(chunks.Hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candidatePath := range candidates {
+			if candidatePath == path {
+				continue
+			}
+			candidateSet, ok := pathToSet[candidatePath]
+			if !ok {
+				continue
+			}
+
+			pairKey := [2]string{path, candidatePath}
+			if pairKey[0] > pairKey[1] {
+				pairKey[0], pairKey[1] = pairKey[1], pairKey[0]
+			}
+			if _, done := seen[pairKey]; done {
+				continue
+			}
+			seen[pairKey] = struct{}{}
+
+			jaccard := jaccardSimilarity(pathToSet[path], candidateSet)
+			if jaccard >= threshold {
+				pairs = append(pairs, CDCSimilarityPair{Path1: pairKey[0], Path2: pairKey[1], Jaccard: jaccard})
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// jaccardSimilarity returns |a ∩ b| / |a ∪ b| for two sets of chunk hashes.
+func jaccardSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	intersection := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+// WriteCDCSimilarFilesToFile mirrors WriteDuplicateFilesToFile but for the
+// near-duplicate pairs FindSimilarFiles reports (conventionally written to
+// "fav.log.similar"), and emits an EventDuplicateGroup (Kind:"similar") per
+// pair the same way SaveDuplicateFileInfoToRedis's exact-match groups are
+// emitted, so a consumer of the event stream can tell the two apart. main.go
+// wires this in behind -find-similar, alongside -find-simhash
+// (content_similarity.go), -find-partial-dup (content_pieces.go), and
+// -find-byte-overlap (content_cdc_bytes.go); content_rolling_chunk.go's
+// FindSimilar remains the one standalone alternative not wired into the
+// default pipeline - see ProcessFileRollingChunks' doc comment for why.
+func (fp *FileProcessor) WriteCDCSimilarFilesToFile(rootDir, outputFile string, threshold float64) error {
+	pairs, err := fp.FindSimilarFiles(threshold)
+	if err != nil {
+		return fmt.Errorf("error finding similar files: %w", err)
+	}
+
+	outputPath := filepath.Join(rootDir, outputFile)
+	return fp.atomicWrite(outputPath, func(w io.Writer) error {
+		for _, pair := range pairs {
+			groupID := fmt.Sprintf("similar:%s", fp.generateHashFunc(pair.Path1))
+			fp.emit(Event{Type: EventDuplicateGroup, GroupID: groupID, Path: pair.Path1, Kind: "similar"})
+			fp.emit(Event{Type: EventDuplicateGroup, GroupID: groupID, Path: pair.Path2, Kind: "similar"})
+
+			if _, err := fmt.Fprintf(w, "Similar files (Jaccard %.2f):\n", pair.Jaccard); err != nil {
+				return err
+			}
+			for _, path := range []string{pair.Path1, pair.Path2} {
+				if _, err := fmt.Fprintf(w, "[~] %s\n", cleanRelativePath(rootDir, path)); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,295 @@
+// content_cdc_bytes.go
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixChunkOffsets = "hashedKeyToChunkOffsets:"
+	keyPrefixChunkHashSet = "chunkHash:"
+	entrySeparator        = "\x00"
+
+	// defaultByteOverlapThreshold is the minimum shared-chunk-bytes /
+	// min(size) fraction FindNearDuplicatesByBytes requires before reporting
+	// a pair, used when a caller passes a non-positive threshold.
+	defaultByteOverlapThreshold = 0.5
+)
+
+// ChunkOffsets is the gob-encoded value stored under
+// hashedKeyToChunkOffsets:<hashedKey>: every content-defined chunk this file
+// was split into, each with its byte size, so FindNearDuplicatesByBytes can
+// weigh a match by how much content two files actually share rather than how
+// many chunks they happen to have in common.
+type ChunkOffsets struct {
+	Chunks []cdcChunk
+}
+
+func getChunkOffsetsKey(hashedKey string) string {
+	return keyPrefixChunkOffsets + hashedKey
+}
+
+func getChunkHashSetKey(chunkHash string) string {
+	return keyPrefixChunkHashSet + chunkHash
+}
+
+// chunkHashSetEntry formats a chunkHash:<hash> set member as
+// "<hashedKey>\x00<offset>", so a single Redis set can record every
+// occurrence of a chunk across files without a second lookup per file.
+func chunkHashSetEntry(hashedKey string, offset int64) string {
+	return hashedKey + entrySeparator + strconv.FormatInt(offset, 10)
+}
+
+func splitChunkHashSetEntry(entry string) (hashedKey string, offset int64, ok bool) {
+	i := strings.LastIndex(entry, entrySeparator)
+	if i < 0 {
+		return "", 0, false
+	}
+	offset, err := strconv.ParseInt(entry[i+len(entrySeparator):], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return entry[:i], offset, true
+}
+
+// ProcessFileChunkOffsets splits fullPath into content-defined chunks (using
+// fp.CDCAvgChunkSize/CDCMinChunkSize/CDCMaxChunkSize, see WithCDCChunkSizes)
+// and persists each chunk's hash, offset, and size under
+// hashedKeyToChunkOffsets:<hashedKey>, recording fullPath's hashedKey and
+// offset in each chunk's chunkHash:<hash> bucket as the reverse index
+// FindNearDuplicatesByBytes reads from. Because it reuses splitCDCChunks, two
+// files sharing a run of identical content produce identical chunk
+// boundaries - and therefore identical chunk hashes - for that run
+// regardless of what precedes or follows it in either file.
+//
+// Unlike content_cdc.go's FindSimilarFiles, which scores overlap as a
+// Jaccard ratio over chunk hashes, FindNearDuplicatesByBytes weighs each
+// shared chunk by its byte size - more useful when files vary a lot in
+// how many chunks they split into. main.go's -find-byte-overlap wires this
+// in alongside -find-similar's Jaccard-based pass, for callers that want the
+// byte-weighted score instead.
+func (fp *FileProcessor) ProcessFileChunkOffsets(fullPath string) error {
+	chunks, err := fp.splitCDCChunks(fullPath, fp.CDCAvgChunkSize, fp.CDCMinChunkSize, fp.CDCMaxChunkSize)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ChunkOffsets{Chunks: chunks}); err != nil {
+		return fmt.Errorf("error encoding chunk offsets for %s: %w", fullPath, err)
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+
+	pipe := fp.Rdb.Pipeline()
+	pipe.Set(fp.Ctx, getChunkOffsetsKey(hashedKey), buf.Bytes(), 0)
+	for _, c := range chunks {
+		pipe.SAdd(fp.Ctx, getChunkHashSetKey(c.Hash), chunkHashSetEntry(hashedKey, c.Offset))
+	}
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return fmt.Errorf("error saving chunk offsets for %s: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// getChunkOffsets reads back the ChunkOffsets stored for hashedKey.
+func (fp *FileProcessor) getChunkOffsets(hashedKey string) (ChunkOffsets, error) {
+	var offsets ChunkOffsets
+	value, err := fp.Rdb.Get(fp.Ctx, getChunkOffsetsKey(hashedKey)).Bytes()
+	if err != nil {
+		return offsets, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&offsets); err != nil {
+		return offsets, fmt.Errorf("error decoding chunk offsets: %w", err)
+	}
+	return offsets, nil
+}
+
+// scanChunkOffsets returns every hashedKey -> ChunkOffsets pair currently
+// stored.
+func (fp *FileProcessor) scanChunkOffsets() (map[string]ChunkOffsets, error) {
+	result := make(map[string]ChunkOffsets)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixChunkOffsets+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixChunkOffsets)
+
+		offsets, err := fp.getChunkOffsets(hashedKey)
+		if err != nil {
+			continue
+		}
+		result[hashedKey] = offsets
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning chunk offsets: %w", err)
+	}
+	return result, nil
+}
+
+// candidateChunkHashedKeys returns every other hashedKey sharing at least one
+// of chunkHashes with hashedKey, read from the chunkHash reverse index.
+func (fp *FileProcessor) candidateChunkHashedKeys(hashedKey string, chunks []cdcChunk) (map[string]struct{}, error) {
+	candidates := make(map[string]struct{})
+	for _, c := range chunks {
+		members, err := fp.Rdb.SMembers(fp.Ctx, getChunkHashSetKey(c.Hash)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading chunk hash bucket %s: %w", c.Hash, err)
+		}
+		for _, member := range members {
+			otherKey, _, ok := splitChunkHashSetEntry(member)
+			if !ok || otherKey == hashedKey {
+				continue
+			}
+			candidates[otherKey] = struct{}{}
+		}
+	}
+	return candidates, nil
+}
+
+// ByteOverlapPair is a near-duplicate match found by FindNearDuplicatesByBytes:
+// Path1 and Path2 share at least SharedBytes bytes of content, which is
+// OverlapFraction of the smaller file's total size.
+type ByteOverlapPair struct {
+	Path1           string
+	Path2           string
+	SharedBytes     int64
+	OverlapFraction float64
+}
+
+// sharedChunkBytes sums the size of every chunk a and b have in common,
+// counting a repeated chunk hash within one file's chunk list only once per
+// occurrence in the other file so two files that both contain a long run of
+// zeroes don't inflate their overlap beyond what's actually shared.
+func sharedChunkBytes(a, b []cdcChunk) int64 {
+	bCounts := make(map[string]int, len(b))
+	for _, c := range b {
+		bCounts[c.Hash]++
+	}
+
+	var shared int64
+	for _, c := range a {
+		if bCounts[c.Hash] > 0 {
+			shared += c.Size
+			bCounts[c.Hash]--
+		}
+	}
+	return shared
+}
+
+// FindNearDuplicatesByBytes scans every file with chunk offsets recorded and
+// pairs up files whose shared-chunk-bytes / min(size) meets or exceeds
+// threshold (<=0 uses defaultByteOverlapThreshold). Unlike FindSimilarFiles'
+// Jaccard similarity over the set of distinct chunk hashes, this weighs each
+// match by how many bytes it actually represents, so a pair sharing one huge
+// chunk and differing in many small ones is scored the same way a human
+// skimming the files would: by how much of the smaller file is accounted
+// for.
+func (fp *FileProcessor) FindNearDuplicatesByBytes(threshold float64) ([]ByteOverlapPair, error) {
+	if threshold <= 0 {
+		threshold = defaultByteOverlapThreshold
+	}
+
+	hashedKeyToOffsets, err := fp.scanChunkOffsets()
+	if err != nil {
+		return nil, err
+	}
+
+	sizeOf := func(offsets ChunkOffsets) int64 {
+		var total int64
+		for _, c := range offsets.Chunks {
+			total += c.Size
+		}
+		return total
+	}
+
+	seen := make(map[[2]string]struct{})
+	var pairs []ByteOverlapPair
+
+	for hashedKey, offsets := range hashedKeyToOffsets {
+		candidates, err := fp.candidateChunkHashedKeys(hashedKey, offsets.Chunks)
+		if err != nil {
+			return nil, err
+		}
+
+		for candidateKey := range candidates {
+			candidateOffsets, ok := hashedKeyToOffsets[candidateKey]
+			if !ok {
+				continue
+			}
+
+			pairKey := [2]string{hashedKey, candidateKey}
+			if pairKey[0] > pairKey[1] {
+				pairKey[0], pairKey[1] = pairKey[1], pairKey[0]
+			}
+			if _, done := seen[pairKey]; done {
+				continue
+			}
+			seen[pairKey] = struct{}{}
+
+			shared := sharedChunkBytes(offsets.Chunks, candidateOffsets.Chunks)
+			minSize := sizeOf(offsets)
+			if other := sizeOf(candidateOffsets); other < minSize {
+				minSize = other
+			}
+			if minSize == 0 {
+				continue
+			}
+
+			fraction := float64(shared) / float64(minSize)
+			if fraction < threshold {
+				continue
+			}
+
+			path1, err1 := fp.Rdb.Get(fp.Ctx, getHashedKeyToPathKey(pairKey[0])).Result()
+			path2, err2 := fp.Rdb.Get(fp.Ctx, getHashedKeyToPathKey(pairKey[1])).Result()
+			if err1 != nil || err2 != nil {
+				continue
+			}
+
+			pairs = append(pairs, ByteOverlapPair{
+				Path1:           path1,
+				Path2:           path2,
+				SharedBytes:     shared,
+				OverlapFraction: fraction,
+			})
+		}
+	}
+
+	return pairs, nil
+}
+
+// WriteByteOverlapsToFile mirrors WriteDuplicateFilesToFile but for the
+// byte-weighted near-duplicate pairs FindNearDuplicatesByBytes reports
+// (conventionally written to "fav.log.byte-overlap").
+func (fp *FileProcessor) WriteByteOverlapsToFile(rootDir, outputFile string, threshold float64) error {
+	pairs, err := fp.FindNearDuplicatesByBytes(threshold)
+	if err != nil {
+		return fmt.Errorf("error finding byte overlaps: %w", err)
+	}
+
+	outputPath := filepath.Join(rootDir, outputFile)
+	return fp.atomicWrite(outputPath, func(w io.Writer) error {
+		for _, pair := range pairs {
+			if _, err := fmt.Fprintf(w, "Byte overlap (%.2f, %d shared bytes):\n", pair.OverlapFraction, pair.SharedBytes); err != nil {
+				return err
+			}
+			for _, path := range []string{pair.Path1, pair.Path2} {
+				if _, err := fmt.Fprintf(w, "[~] %s\n", cleanRelativePath(rootDir, path)); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
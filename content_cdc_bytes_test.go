@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_ProcessFileChunkOffsets(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	fp.CDCMinChunkSize = 16
+	fp.CDCAvgChunkSize = 32
+	fp.CDCMaxChunkSize = 64
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", []byte(strings.Repeat("AB", 64)), 0644))
+	require.NoError(t, fp.ProcessFileChunkOffsets("/a.bin"))
+
+	hashedKey := generateHash("/a.bin")
+	offsets, err := fp.getChunkOffsets(hashedKey)
+	require.NoError(t, err)
+	require.NotEmpty(t, offsets.Chunks)
+
+	members, err := rdb.SMembers(ctx, getChunkHashSetKey(offsets.Chunks[0].Hash)).Result()
+	require.NoError(t, err)
+	require.NotEmpty(t, members)
+
+	memberKey, memberOffset, ok := splitChunkHashSetEntry(members[0])
+	require.True(t, ok)
+	assert.Equal(t, hashedKey, memberKey)
+	assert.Equal(t, offsets.Chunks[0].Offset, memberOffset)
+}
+
+func TestFileProcessor_FindNearDuplicatesByBytes(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	fp.CDCMinChunkSize = 16
+	fp.CDCAvgChunkSize = 32
+	fp.CDCMaxChunkSize = 64
+
+	shared := strings.Repeat("AB", 64)
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", []byte(shared+"HEADER-A"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.bin", []byte(shared+"HEADER-B"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/c.bin", []byte(strings.Repeat("ZZ", 64)), 0644))
+
+	require.NoError(t, fp.ProcessFile("/", "a.bin", true))
+	require.NoError(t, fp.ProcessFile("/", "b.bin", true))
+	require.NoError(t, fp.ProcessFile("/", "c.bin", true))
+
+	require.NoError(t, fp.ProcessFileChunkOffsets("/a.bin"))
+	require.NoError(t, fp.ProcessFileChunkOffsets("/b.bin"))
+	require.NoError(t, fp.ProcessFileChunkOffsets("/c.bin"))
+
+	pairs, err := fp.FindNearDuplicatesByBytes(0.5)
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	assert.ElementsMatch(t, []string{"/a.bin", "/b.bin"}, []string{pairs[0].Path1, pairs[0].Path2})
+	assert.Greater(t, pairs[0].SharedBytes, int64(0))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pseudoRandomBytes returns deterministic, non-repeating content seeded by
+// seed, so the gear-hash rolling hash sees enough variation to place
+// boundaries (rather than tripping on every offset of a uniform byte
+// string) and distinct seeds produce unrelated content.
+func pseudoRandomBytes(seed uint32, n int) []byte {
+	b := make([]byte, n)
+	x := seed
+	for i := range b {
+		x = x*1664525 + 1013904223
+		b[i] = byte(x >> 24)
+	}
+	return b
+}
+
+func TestFileProcessor_CalculateCDCChunkHashes(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	content := pseudoRandomBytes(1, 20000)
+	require.NoError(t, afero.WriteFile(fs, "/cdc_test.bin", content, 0644))
+
+	hashes, err := fp.calculateCDCChunkHashes("/cdc_test.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hashes)
+
+	hashesAgain, err := fp.calculateCDCChunkHashes("/cdc_test.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	assert.Equal(t, hashes, hashesAgain)
+}
+
+func TestFileProcessor_ProcessFileCDC(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.CDCAvgChunkSize, fp.CDCMinChunkSize, fp.CDCMaxChunkSize = 2048, 512, 8192
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", pseudoRandomBytes(1, 20000), 0644))
+	require.NoError(t, fp.ProcessFileCDC("/a.bin"))
+
+	hashedKey := generateHash("/a.bin")
+	chunks, err := fp.getCDCChunks(hashedKey)
+	require.NoError(t, err)
+	assert.NotEmpty(t, chunks.Hashes)
+
+	members, err := rdb.SMembers(ctx, getCDCChunkToPathSetKey(chunks.Hashes[0])).Result()
+	require.NoError(t, err)
+	assert.Contains(t, members, "/a.bin")
+}
+
+// TestFileProcessor_CDCSurvivesShiftedPrefix is the chunk2-4 acceptance
+// scenario: a re-muxed copy of a file with a few bytes spliced in at the
+// front (simulating a different container header) shifts every fixed-size
+// piece boundary, so content_pieces.go's piece hashing shares nothing between
+// the two files. Content-defined chunking's boundaries are determined by the
+// content itself, so once the rolling hash resyncs past the inserted bytes
+// the two files still share the same chunk hashes.
+func TestFileProcessor_CDCSurvivesShiftedPrefix(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	original := pseudoRandomBytes(1, 40000)
+	shifted := append(append([]byte{}, pseudoRandomBytes(2, 37)...), original...)
+
+	require.NoError(t, afero.WriteFile(fs, "/original.bin", original, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/shifted.bin", shifted, 0644))
+
+	pieceHashesOriginal, err := fp.calculatePieceHashes("/original.bin", 4096)
+	require.NoError(t, err)
+	pieceHashesShifted, err := fp.calculatePieceHashes("/shifted.bin", 4096)
+	require.NoError(t, err)
+
+	assert.Empty(t, intersect(pieceHashesOriginal, pieceHashesShifted),
+		"fixed-size pieces should share nothing once every boundary is shifted")
+
+	cdcHashesOriginal, err := fp.calculateCDCChunkHashes("/original.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	cdcHashesShifted, err := fp.calculateCDCChunkHashes("/shifted.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, intersect(cdcHashesOriginal, cdcHashesShifted),
+		"content-defined chunking should resync and share chunks past the shifted prefix")
+}
+
+func intersect(a, b []string) []string {
+	set := make(map[string]struct{}, len(a))
+	for _, h := range a {
+		set[h] = struct{}{}
+	}
+	var common []string
+	for _, h := range b {
+		if _, ok := set[h]; ok {
+			common = append(common, h)
+		}
+	}
+	return common
+}
+
+// seedHashedKeyToPath records the path -> hashedKey -> path round trip that
+// ProcessFile normally sets up, so tests can exercise a secondary processor
+// (ProcessFileCDC, ProcessFileContentSimilarity, ...) in isolation without
+// going through a full ProcessFile call.
+func seedHashedKeyToPath(t *testing.T, fp *FileProcessor, path string) {
+	t.Helper()
+	hashedKey := generateHash(path)
+	require.NoError(t, fp.Rdb.Set(fp.Ctx, getHashedKeyToPathKey(hashedKey), path, 0).Err())
+}
+
+func TestFileProcessor_FindSimilarFiles(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.CDCAvgChunkSize, fp.CDCMinChunkSize, fp.CDCMaxChunkSize = 2048, 512, 8192
+
+	original := pseudoRandomBytes(1, 40000)
+	shifted := append(append([]byte{}, pseudoRandomBytes(2, 37)...), original...)
+	unrelated := pseudoRandomBytes(3, 40000)
+
+	require.NoError(t, afero.WriteFile(fs, "/original.bin", original, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/shifted.bin", shifted, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/unrelated.bin", unrelated, 0644))
+
+	for _, path := range []string{"/original.bin", "/shifted.bin", "/unrelated.bin"} {
+		seedHashedKeyToPath(t, fp, path)
+	}
+
+	require.NoError(t, fp.ProcessFileCDC("/original.bin"))
+	require.NoError(t, fp.ProcessFileCDC("/shifted.bin"))
+	require.NoError(t, fp.ProcessFileCDC("/unrelated.bin"))
+
+	pairs, err := fp.FindSimilarFiles(0.5)
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	assert.ElementsMatch(t, []string{"/original.bin", "/shifted.bin"}, []string{pairs[0].Path1, pairs[0].Path2})
+	assert.Greater(t, pairs[0].Jaccard, 0.5)
+}
+
+func TestJaccardSimilarity(t *testing.T) {
+	a := map[string]struct{}{"1": {}, "2": {}, "3": {}}
+	b := map[string]struct{}{"2": {}, "3": {}, "4": {}}
+
+	assert.InDelta(t, 0.5, jaccardSimilarity(a, b), 0.0001)
+	assert.Equal(t, float64(1), jaccardSimilarity(a, a))
+	assert.Equal(t, float64(1), jaccardSimilarity(map[string]struct{}{}, map[string]struct{}{}))
+}
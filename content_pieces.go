@@ -0,0 +1,251 @@
+// content_pieces.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixPieceHashes      = "hashedKeyToPieces:"
+	keyPrefixPieceHashToPaths = "pieceHashToPathSet:"
+
+	// defaultPieceSize is the chunk size piece-wise hashing splits a file
+	// into, mirroring a torrent's piece list so files that only share part
+	// of their content (re-encoded intros, appended commercials, truncated
+	// copies) can still be matched.
+	defaultPieceSize = 4 * 1024 * 1024 // 4 MiB
+
+	// defaultMinSharedPieces is how many piece hashes two files must have in
+	// common before FindPartialOverlaps reports them as a partial-overlap
+	// candidate.
+	defaultMinSharedPieces = 4
+)
+
+// PieceHashes is the gob-encoded value stored under
+// hashedKeyToPieces:<hashedKey>: the path it was computed for, the piece size
+// used to split the file, and the ordered SHA-256 hash of each piece. Path is
+// carried here (rather than resolved through hashedKeyToPath:<hashedKey>)
+// because, unlike the CDC/similarity processors, ProcessFilePieces doesn't
+// assume ProcessFile has already run for fullPath and populated that
+// mapping - scanPieceHashes needs to recover the path from what
+// ProcessFilePieces itself wrote.
+type PieceHashes struct {
+	Path      string
+	PieceSize int64
+	Hashes    []string
+}
+
+func getPieceHashesKey(hashedKey string) string {
+	return keyPrefixPieceHashes + hashedKey
+}
+
+func getPieceHashToPathSetKey(pieceHash string) string {
+	return keyPrefixPieceHashToPaths + pieceHash
+}
+
+// calculatePieceHashes splits fullPath into pieceSize-byte pieces (the last
+// piece may be shorter) and returns the SHA-256 hash of each, in order.
+func (fp *FileProcessor) calculatePieceHashes(fullPath string, pieceSize int64) ([]string, error) {
+	if pieceSize <= 0 {
+		pieceSize = defaultPieceSize
+	}
+
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file for piece hashing: %w", err)
+	}
+	defer f.Close()
+
+	var hashes []string
+	buf := make([]byte, pieceSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			h := sha256.Sum256(buf[:n])
+			hashes = append(hashes, hex.EncodeToString(h[:]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading piece from %s: %w", fullPath, err)
+		}
+	}
+
+	return hashes, nil
+}
+
+// ProcessFilePieces computes fullPath's piece hashes and stores them under
+// hashedKeyToPieces:<hashedKey>, adding fullPath to each piece's
+// pieceHashToPathSet:<pieceHash> as the bucket FindPartialOverlaps reads
+// candidates from.
+//
+// This fixed-size piece scheme is the simplest of the package's
+// near-duplicate detectors, and the one most exposed to shifted content: a
+// few bytes spliced in near the front shifts every piece boundary after it,
+// unlike the content-defined chunkers (content_cdc.go, content_rolling_chunk.go)
+// which resync. main.go's -find-partial-dup wires this in alongside
+// -find-similar/-find-simhash, for callers that specifically want
+// piece-aligned overlap (e.g. confirming a truncated copy shares a file's
+// leading pieces byte-for-byte).
+func (fp *FileProcessor) ProcessFilePieces(fullPath string, pieceSize int64) error {
+	if pieceSize <= 0 {
+		pieceSize = defaultPieceSize
+	}
+
+	hashes, err := fp.calculatePieceHashes(fullPath, pieceSize)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(PieceHashes{Path: fullPath, PieceSize: pieceSize, Hashes: hashes}); err != nil {
+		return fmt.Errorf("error encoding piece hashes for %s: %w", fullPath, err)
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+
+	pipe := fp.Rdb.Pipeline()
+	pipe.Set(fp.Ctx, getPieceHashesKey(hashedKey), buf.Bytes(), 0)
+	for _, pieceHash := range hashes {
+		pipe.SAdd(fp.Ctx, getPieceHashToPathSetKey(pieceHash), fullPath)
+	}
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return fmt.Errorf("error saving piece hashes for %s: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// getPieceHashes reads back the PieceHashes stored for hashedKey.
+func (fp *FileProcessor) getPieceHashes(hashedKey string) (PieceHashes, error) {
+	var pieces PieceHashes
+	value, err := fp.Rdb.Get(fp.Ctx, getPieceHashesKey(hashedKey)).Bytes()
+	if err != nil {
+		return pieces, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&pieces); err != nil {
+		return pieces, fmt.Errorf("error decoding piece hashes: %w", err)
+	}
+	return pieces, nil
+}
+
+// scanPieceHashes returns every path -> PieceHashes pair currently stored.
+func (fp *FileProcessor) scanPieceHashes() (map[string]PieceHashes, error) {
+	result := make(map[string]PieceHashes)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixPieceHashes+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixPieceHashes)
+
+		pieces, err := fp.getPieceHashes(hashedKey)
+		if err != nil {
+			continue
+		}
+
+		result[pieces.Path] = pieces
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning piece hashes: %w", err)
+	}
+	return result, nil
+}
+
+// PartialOverlapGroup is a pair of files sharing at least SharedPieces piece
+// hashes, found by FindPartialOverlaps.
+type PartialOverlapGroup struct {
+	SharedPieces int
+	Paths        []string
+}
+
+// FindPartialOverlaps scans every file that has piece hashes recorded and
+// pairs up files sharing at least minSharedPieces (<=0 uses
+// defaultMinSharedPieces) of those pieces, catching media files that only
+// share part of their content - a re-encoded intro, an appended commercial,
+// a truncated copy - that neither the head hash nor the full hash can see.
+func (fp *FileProcessor) FindPartialOverlaps(minSharedPieces int) ([]PartialOverlapGroup, error) {
+	if minSharedPieces <= 0 {
+		minSharedPieces = defaultMinSharedPieces
+	}
+
+	pathToPieces, err := fp.scanPieceHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each shared piece is counted once from each side of the pair (once
+	// while walking path's pieces, once while walking the other path's), so
+	// the running total is double the true shared-piece count.
+	sharedTwice := make(map[[2]string]int)
+	for path, pieces := range pathToPieces {
+		seen := make(map[string]struct{}, len(pieces.Hashes))
+		for _, pieceHash := range pieces.Hashes {
+			if _, dup := seen[pieceHash]; dup {
+				continue
+			}
+			seen[pieceHash] = struct{}{}
+
+			members, err := fp.Rdb.SMembers(fp.Ctx, getPieceHashToPathSetKey(pieceHash)).Result()
+			if err != nil && err != redis.Nil {
+				return nil, fmt.Errorf("error reading piece bucket %s: %w", pieceHash, err)
+			}
+			for _, other := range members {
+				if other == path {
+					continue
+				}
+				pairKey := [2]string{path, other}
+				if pairKey[0] > pairKey[1] {
+					pairKey[0], pairKey[1] = pairKey[1], pairKey[0]
+				}
+				sharedTwice[pairKey]++
+			}
+		}
+	}
+
+	var groups []PartialOverlapGroup
+	for pairKey, twice := range sharedTwice {
+		count := twice / 2
+		if count >= minSharedPieces {
+			groups = append(groups, PartialOverlapGroup{SharedPieces: count, Paths: []string{pairKey[0], pairKey[1]}})
+		}
+	}
+
+	return groups, nil
+}
+
+// WritePartialDuplicatesToFile mirrors WriteDuplicateFilesToFile but for
+// partial-overlap groups found by FindPartialOverlaps (conventionally written
+// to "fav.log.partial-dup"), so users can inspect files that share large
+// content ranges without being byte-identical.
+func (fp *FileProcessor) WritePartialDuplicatesToFile(rootDir, outputFile string, minSharedPieces int) error {
+	groups, err := fp.FindPartialOverlaps(minSharedPieces)
+	if err != nil {
+		return fmt.Errorf("error finding partial overlaps: %w", err)
+	}
+
+	outputPath := filepath.Join(rootDir, outputFile)
+	return fp.atomicWrite(outputPath, func(w io.Writer) error {
+		for _, group := range groups {
+			if _, err := fmt.Fprintf(w, "Partial overlap (%d shared pieces):\n", group.SharedPieces); err != nil {
+				return err
+			}
+			for _, path := range group.Paths {
+				if _, err := fmt.Fprintf(w, "[~] %s\n", cleanRelativePath(rootDir, path)); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_CalculatePieceHashes(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	content := make([]byte, 10)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	require.NoError(t, afero.WriteFile(fs, "/piece_test.bin", content, 0644))
+
+	hashes, err := fp.calculatePieceHashes("/piece_test.bin", 4)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 3) // 4 + 4 + 2 bytes
+
+	hashesAgain, err := fp.calculatePieceHashes("/piece_test.bin", 4)
+	require.NoError(t, err)
+	assert.Equal(t, hashes, hashesAgain)
+}
+
+func TestFileProcessor_ProcessFilePieces(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", []byte("AAAABBBB"), 0644))
+	require.NoError(t, fp.ProcessFilePieces("/a.bin", 4))
+
+	hashedKey := generateHash("/a.bin")
+	pieces, err := fp.getPieceHashes(hashedKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), pieces.PieceSize)
+	assert.Len(t, pieces.Hashes, 2)
+
+	members, err := rdb.SMembers(ctx, getPieceHashToPathSetKey(pieces.Hashes[0])).Result()
+	require.NoError(t, err)
+	assert.Contains(t, members, "/a.bin")
+}
+
+func TestFileProcessor_FindPartialOverlaps(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	// /a.bin and /b.bin share their first piece ("AAAA") but diverge after.
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", []byte("AAAABBBBCCCCDDDD"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.bin", []byte("AAAAEEEEFFFFGGGG"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/c.bin", []byte("ZZZZYYYYXXXXWWWW"), 0644))
+
+	require.NoError(t, afero.WriteFile(fs, "/d.bin", []byte("AAAABBBBCCCCHHHH"), 0644))
+
+	require.NoError(t, fp.ProcessFilePieces("/a.bin", 4))
+	require.NoError(t, fp.ProcessFilePieces("/b.bin", 4))
+	require.NoError(t, fp.ProcessFilePieces("/c.bin", 4))
+	require.NoError(t, fp.ProcessFilePieces("/d.bin", 4))
+
+	// /a.bin and /d.bin share 3 of their 4 pieces.
+	groups, err := fp.FindPartialOverlaps(3)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, 3, groups[0].SharedPieces)
+	assert.ElementsMatch(t, []string{"/a.bin", "/d.bin"}, groups[0].Paths)
+
+	// Lowering the threshold also surfaces the single-piece overlaps.
+	looseGroups, err := fp.FindPartialOverlaps(1)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(looseGroups), 3)
+}
+
+func TestFileProcessor_WritePartialDuplicatesToFile(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/a.bin", []byte("AAAABBBBCCCCDDDD"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/d.bin", []byte("AAAABBBBCCCCHHHH"), 0644))
+
+	require.NoError(t, fp.ProcessFilePieces("/root/a.bin", 4))
+	require.NoError(t, fp.ProcessFilePieces("/root/d.bin", 4))
+
+	require.NoError(t, fp.WritePartialDuplicatesToFile("/root", "fav.log.partial-dup", 3))
+
+	content, err := afero.ReadFile(fs, "/root/fav.log.partial-dup")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Partial overlap (3 shared pieces):")
+	assert.Contains(t, string(content), "[~]")
+}
@@ -0,0 +1,279 @@
+// content_rolling_chunk.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixChunkSet     = "chunks:"
+	keyPrefixChunkToFiles = "chunkToFiles:"
+
+	// rollingWindowSize is the width, in bytes, of the trailing window
+	// rollingHash's Rabin fingerprint covers.
+	rollingWindowSize = 64
+
+	// rollingPrime is the multiplier the Rabin rolling hash advances by per
+	// byte; rollingPrimePowWindow is rollingPrime^rollingWindowSize mod 2^64,
+	// precomputed once so removing the byte that's falling out of the
+	// window is an O(1) multiply-and-subtract rather than a loop.
+	rollingPrime = 1000000007
+
+	// defaultRollingAvgChunkSize, defaultRollingMinChunkSize, and
+	// defaultRollingMaxChunkSize are the chunk-size targets
+	// ProcessFileRollingChunks uses when a FileProcessor doesn't override
+	// them: a 1 MiB average chunk, clamped to [512 KiB, 8 MiB]. These differ
+	// from content_cdc.go's gear-hash defaults because the two chunkers
+	// store to different Redis schemas (see keyPrefixChunkSet vs
+	// keyPrefixCDCChunks) for independent callers to pick from.
+	defaultRollingAvgChunkSize = 1 * 1024 * 1024
+	defaultRollingMinChunkSize = 512 * 1024
+	defaultRollingMaxChunkSize = 8 * 1024 * 1024
+
+	// defaultRollingSimilarityThreshold is the minimum chunk-Jaccard overlap
+	// FindSimilar reports a candidate at when the caller passes threshold<=0.
+	defaultRollingSimilarityThreshold = 0.5
+)
+
+var rollingPrimePowWindow uint64
+
+func init() {
+	p := uint64(1)
+	for i := 0; i < rollingWindowSize; i++ {
+		p *= rollingPrime
+	}
+	rollingPrimePowWindow = p
+}
+
+func getChunkSetKey(hashedKey string) string {
+	return keyPrefixChunkSet + hashedKey
+}
+
+func getChunkToFilesKey(chunkHash string) string {
+	return keyPrefixChunkToFiles + chunkHash
+}
+
+// splitRollingChunks splits fullPath into content-defined chunks using a
+// Rabin rolling hash over a rollingWindowSize-byte sliding window: advancing
+// by one byte updates h as h*prime + byte_in - byte_out*prime^window (mod
+// 2^64, via uint64 overflow), and a boundary falls wherever h&mask == 0,
+// clamped to [minSize, maxSize] so no chunk is pathologically small or
+// unbounded. It returns each chunk's SHA-256 hash, in order. Non-positive
+// sizes fall back to the package defaults.
+func (fp *FileProcessor) splitRollingChunks(fullPath string, avgSize, minSize, maxSize int) ([]string, error) {
+	if avgSize <= 0 {
+		avgSize = defaultRollingAvgChunkSize
+	}
+	if minSize <= 0 {
+		minSize = defaultRollingMinChunkSize
+	}
+	if maxSize <= 0 {
+		maxSize = defaultRollingMaxChunkSize
+	}
+	mask := cdcMask(avgSize)
+
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file for rolling-hash chunking: %w", err)
+	}
+	defer f.Close()
+
+	var hashes []string
+	var current []byte
+	var window [rollingWindowSize]byte
+	var windowLen, windowPos int
+	var h uint64
+
+	flush := func() {
+		sum := sha256.Sum256(current)
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+		current = current[:0]
+		h = 0
+		windowLen = 0
+		windowPos = 0
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, rerr := f.Read(buf)
+		for i := 0; i < n; i++ {
+			b := buf[i]
+			current = append(current, b)
+
+			var out byte
+			if windowLen == rollingWindowSize {
+				out = window[windowPos]
+			}
+			window[windowPos] = b
+			windowPos = (windowPos + 1) % rollingWindowSize
+			if windowLen < rollingWindowSize {
+				windowLen++
+			}
+
+			h = h*rollingPrime + uint64(b) - uint64(out)*rollingPrimePowWindow
+
+			if len(current) >= minSize && windowLen == rollingWindowSize && (len(current) >= maxSize || h&mask == 0) {
+				flush()
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, fmt.Errorf("error reading %s for rolling-hash chunking: %w", fullPath, rerr)
+		}
+	}
+	if len(current) > 0 {
+		flush()
+	}
+
+	return hashes, nil
+}
+
+// ProcessFileRollingChunks computes fullPath's Rabin rolling-hash chunk
+// hashes using fp.RollingAvgChunkSize/RollingMinChunkSize/RollingMaxChunkSize
+// (see WithRollingChunkSizes) and records each as a member of
+// chunks:<hashedKey>, with fullPath added to chunkToFiles:<chunkSHA> as the
+// lookup FindSimilar walks in the other direction. Re-running it for a path
+// whose content changed replaces chunks:<hashedKey> wholesale and drops
+// fullPath from any chunkToFiles set the old content referenced but the new
+// one doesn't, rather than accumulating stale members from every prior run.
+//
+// This predates content_cdc.go's gear-hash chunker, which main.go's
+// -find-similar flag wires into the scan; ProcessFileRollingChunks/FindSimilar
+// remain here as a standalone alternative (a different rolling-hash
+// construction, its own chunks:/chunkToFiles: keyspace) for direct callers
+// and tests to compare against, not as a second scan-time code path.
+func (fp *FileProcessor) ProcessFileRollingChunks(fullPath string) error {
+	hashes, err := fp.splitRollingChunks(fullPath, fp.RollingAvgChunkSize, fp.RollingMinChunkSize, fp.RollingMaxChunkSize)
+	if err != nil {
+		return err
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+	chunkSetKey := getChunkSetKey(hashedKey)
+
+	oldChunkHashes, err := fp.Rdb.SMembers(fp.Ctx, chunkSetKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("error reading previous rolling chunks for %s: %w", fullPath, err)
+	}
+
+	newChunkHashes := make(map[string]struct{}, len(hashes))
+	for _, chunkHash := range hashes {
+		newChunkHashes[chunkHash] = struct{}{}
+	}
+
+	pipe := fp.Rdb.Pipeline()
+	pipe.Del(fp.Ctx, chunkSetKey)
+	for chunkHash := range newChunkHashes {
+		pipe.SAdd(fp.Ctx, chunkSetKey, chunkHash)
+		pipe.SAdd(fp.Ctx, getChunkToFilesKey(chunkHash), fullPath)
+	}
+	for _, chunkHash := range oldChunkHashes {
+		if _, stillPresent := newChunkHashes[chunkHash]; !stillPresent {
+			pipe.SRem(fp.Ctx, getChunkToFilesKey(chunkHash), fullPath)
+		}
+	}
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return fmt.Errorf("error saving rolling chunks for %s: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// SimilarFile is one near-duplicate match FindSimilar reports: otherPath's
+// chunk set overlaps the queried path's by Jaccard.
+type SimilarFile struct {
+	Path    string
+	Jaccard float64
+}
+
+// FindSimilar returns every other file whose Rabin rolling-hash chunk set
+// (see ProcessFileRollingChunks) overlaps path's by at least threshold
+// (<=0 uses defaultRollingSimilarityThreshold), measured as Jaccard
+// similarity. Candidates come from the chunkToFiles:<chunkSHA> reverse index
+// of path's own chunks, and each candidate's overlap is computed with
+// SINTERSTORE into a scratch key rather than reading both chunk sets into
+// the process, so the comparison scales with Redis's set operations instead
+// of the number of chunks per file.
+func (fp *FileProcessor) FindSimilar(path string, threshold float64) ([]SimilarFile, error) {
+	if threshold <= 0 {
+		threshold = defaultRollingSimilarityThreshold
+	}
+
+	hashedKey := fp.generateHashFunc(path)
+	chunkSetKey := getChunkSetKey(hashedKey)
+
+	chunkHashes, err := fp.Rdb.SMembers(fp.Ctx, chunkSetKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error reading chunk set for %s: %w", path, err)
+	}
+	if len(chunkHashes) == 0 {
+		return nil, nil
+	}
+
+	pathSize, err := fp.Rdb.SCard(fp.Ctx, chunkSetKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error counting chunk set for %s: %w", path, err)
+	}
+
+	candidates := make(map[string]struct{})
+	for _, chunkHash := range chunkHashes {
+		members, err := fp.Rdb.SMembers(fp.Ctx, getChunkToFilesKey(chunkHash)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading chunk bucket %s: %w", chunkHash, err)
+		}
+		for _, m := range members {
+			if m == path {
+				continue
+			}
+			candidates[m] = struct{}{}
+		}
+	}
+
+	var matches []SimilarFile
+	for candidatePath := range candidates {
+		candidateKey := getChunkSetKey(fp.generateHashFunc(candidatePath))
+
+		candidateSize, err := fp.Rdb.SCard(fp.Ctx, candidateKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error counting chunk set for %s: %w", candidatePath, err)
+		}
+
+		scratchKey := chunkSetKey + ":vs:" + candidateKey
+		intersection, err := fp.Rdb.SInterStore(fp.Ctx, scratchKey, chunkSetKey, candidateKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error intersecting chunk sets for %s and %s: %w", path, candidatePath, err)
+		}
+		if err := fp.Rdb.Del(fp.Ctx, scratchKey).Err(); err != nil {
+			log.Printf("Warning: failed to clean up scratch intersection key %s: %v", scratchKey, err)
+		}
+
+		union := pathSize + candidateSize - intersection
+		if union == 0 {
+			continue
+		}
+		jaccard := float64(intersection) / float64(union)
+		if jaccard >= threshold {
+			matches = append(matches, SimilarFile{Path: candidatePath, Jaccard: jaccard})
+		}
+	}
+
+	return matches, nil
+}
+
+// EmitSimilarGroup reports path and otherPath as a near-duplicate pair
+// through fp.EventSink via the same EventDuplicateGroup type
+// SaveDuplicateFileInfoToRedis uses for exact matches, tagged Kind:"similar"
+// so a consumer of the event stream can tell the two apart.
+func (fp *FileProcessor) EmitSimilarGroup(path, otherPath string, jaccard float64) {
+	groupID := fmt.Sprintf("similar:%s", fp.generateHashFunc(path))
+	fp.emit(Event{Type: EventDuplicateGroup, GroupID: groupID, Path: path, Kind: "similar"})
+	fp.emit(Event{Type: EventDuplicateGroup, GroupID: groupID, Path: otherPath, Kind: "similar"})
+}
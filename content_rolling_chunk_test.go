@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_SplitRollingChunks(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	content := pseudoRandomBytes(1, 20000)
+	require.NoError(t, afero.WriteFile(fs, "/rolling_test.bin", content, 0644))
+
+	hashes, err := fp.splitRollingChunks("/rolling_test.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hashes)
+
+	hashesAgain, err := fp.splitRollingChunks("/rolling_test.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	assert.Equal(t, hashes, hashesAgain)
+}
+
+func TestFileProcessor_ProcessFileRollingChunks(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.RollingAvgChunkSize, fp.RollingMinChunkSize, fp.RollingMaxChunkSize = 2048, 512, 8192
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", pseudoRandomBytes(1, 20000), 0644))
+	require.NoError(t, fp.ProcessFileRollingChunks("/a.bin"))
+
+	hashedKey := generateHash("/a.bin")
+	chunkHashes, err := rdb.SMembers(ctx, getChunkSetKey(hashedKey)).Result()
+	require.NoError(t, err)
+	assert.NotEmpty(t, chunkHashes)
+
+	members, err := rdb.SMembers(ctx, getChunkToFilesKey(chunkHashes[0])).Result()
+	require.NoError(t, err)
+	assert.Contains(t, members, "/a.bin")
+}
+
+func TestFileProcessor_ProcessFileRollingChunks_RerunDropsStaleReverseIndex(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.RollingAvgChunkSize, fp.RollingMinChunkSize, fp.RollingMaxChunkSize = 2048, 512, 8192
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", pseudoRandomBytes(1, 20000), 0644))
+	require.NoError(t, fp.ProcessFileRollingChunks("/a.bin"))
+
+	hashedKey := generateHash("/a.bin")
+	oldChunkHashes, err := rdb.SMembers(ctx, getChunkSetKey(hashedKey)).Result()
+	require.NoError(t, err)
+	require.NotEmpty(t, oldChunkHashes)
+
+	require.NoError(t, afero.WriteFile(fs, "/a.bin", pseudoRandomBytes(9, 20000), 0644))
+	require.NoError(t, fp.ProcessFileRollingChunks("/a.bin"))
+
+	newChunkHashes, err := rdb.SMembers(ctx, getChunkSetKey(hashedKey)).Result()
+	require.NoError(t, err)
+	assert.Empty(t, intersect(oldChunkHashes, newChunkHashes), "chunks:<hashedKey> should hold only the new content's chunks")
+	for _, oldChunkHash := range oldChunkHashes {
+		members, err := rdb.SMembers(ctx, getChunkToFilesKey(oldChunkHash)).Result()
+		require.NoError(t, err)
+		assert.NotContains(t, members, "/a.bin", "stale reverse-index entries from the old content should have been dropped")
+	}
+}
+
+// TestFileProcessor_RollingChunksSurviveShiftedPrefix mirrors
+// content_cdc_test.go's TestFileProcessor_CDCSurvivesShiftedPrefix: a copy of
+// a file with a few bytes spliced in at the front shifts every fixed-size
+// piece boundary but only shifts the one rolling-hash chunk the insertion
+// falls in, so most chunk hashes still match once the window resyncs.
+func TestFileProcessor_RollingChunksSurviveShiftedPrefix(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	original := pseudoRandomBytes(1, 40000)
+	shifted := append(append([]byte{}, pseudoRandomBytes(2, 37)...), original...)
+
+	require.NoError(t, afero.WriteFile(fs, "/original.bin", original, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/shifted.bin", shifted, 0644))
+
+	hashesOriginal, err := fp.splitRollingChunks("/original.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+	hashesShifted, err := fp.splitRollingChunks("/shifted.bin", 2048, 512, 8192)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, intersect(hashesOriginal, hashesShifted),
+		"the rolling hash should resync and share chunks past the shifted prefix")
+}
+
+func TestFileProcessor_FindSimilar(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.RollingAvgChunkSize, fp.RollingMinChunkSize, fp.RollingMaxChunkSize = 2048, 512, 8192
+
+	original := pseudoRandomBytes(1, 40000)
+	shifted := append(append([]byte{}, pseudoRandomBytes(2, 37)...), original...)
+	unrelated := pseudoRandomBytes(3, 40000)
+
+	require.NoError(t, afero.WriteFile(fs, "/original.bin", original, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/shifted.bin", shifted, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/unrelated.bin", unrelated, 0644))
+
+	require.NoError(t, fp.ProcessFileRollingChunks("/original.bin"))
+	require.NoError(t, fp.ProcessFileRollingChunks("/shifted.bin"))
+	require.NoError(t, fp.ProcessFileRollingChunks("/unrelated.bin"))
+
+	matches, err := fp.FindSimilar("/original.bin", 0.5)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "/shifted.bin", matches[0].Path)
+	assert.Greater(t, matches[0].Jaccard, 0.5)
+}
+
+func TestFileProcessor_FindSimilar_NoChunksRecordedIsEmpty(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	matches, err := fp.FindSimilar("/never-processed.bin", 0.5)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
@@ -0,0 +1,281 @@
+// content_similarity.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/bits"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixSimHash     = "fileSimHash:"
+	keyPrefixSimhashBand = "simhashBand:"
+
+	simHashBandCount = 4
+	simHashBandBits  = 16 // 64 bits / simHashBandCount
+
+	// simHashShingleSize is the sliding-window size (in bytes) used to build
+	// shingles of text-like content before SimHashing them.
+	simHashShingleSize = 8
+
+	// defaultSimilarityThreshold is the maximum Hamming distance between two
+	// 64-bit SimHashes that still counts as "near-duplicate" (~90% similar).
+	defaultSimilarityThreshold = 6
+)
+
+// computeSimHash builds a 64-bit SimHash over sliding simHashShingleSize-byte
+// shingles of content: each shingle is hashed with 64-bit FNV-1a
+// (fnv64aHash), and each of the 64 bits votes +1/-1 per shingle hash bit; the
+// final hash has bit b set wherever the vote total is positive.
+//
+// This fixed-size sliding window is enough to catch near-duplicate text and
+// logs; content-defined chunking over a rolling fingerprint (so shifted
+// binary/media content still lines up) is a separate piece of work and
+// lands with the CDC subsystem rather than being duplicated here.
+func computeSimHash(content []byte) uint64 {
+	var votes [64]int
+
+	shingleCount := len(content) - simHashShingleSize + 1
+	if shingleCount < 1 {
+		shingleCount = 1
+	}
+
+	for i := 0; i < shingleCount; i++ {
+		end := i + simHashShingleSize
+		if end > len(content) {
+			end = len(content)
+		}
+		h := fnv64aHash(content[i:end])
+		for b := 0; b < 64; b++ {
+			if h&(1<<uint(b)) != 0 {
+				votes[b]++
+			} else {
+				votes[b]--
+			}
+		}
+	}
+
+	var result uint64
+	for b := 0; b < 64; b++ {
+		if votes[b] > 0 {
+			result |= 1 << uint(b)
+		}
+	}
+	return result
+}
+
+func fnv64aHash(b []byte) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= prime64
+	}
+	return h
+}
+
+// hammingDistance64 returns the number of differing bits between a and b.
+func hammingDistance64(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// simHashBandKeys returns the simHashBandCount Redis keys a SimHash is
+// bucketed into, one per simHashBandBits-wide slice of the 64-bit value.
+func simHashBandKeys(hash uint64) [simHashBandCount]string {
+	var keys [simHashBandCount]string
+	for b := 0; b < simHashBandCount; b++ {
+		shift := uint(b * simHashBandBits)
+		band := (hash >> shift) & ((1 << simHashBandBits) - 1)
+		keys[b] = fmt.Sprintf("%s%d:%s", keyPrefixSimhashBand, b, strconv.FormatUint(band, 16))
+	}
+	return keys
+}
+
+// saveSimHash stores fullPath's SimHash under fileSimHash:<hashedKey> and
+// adds fullPath to each of the 4 banded sets, so a later candidate lookup is
+// a union of 4 Redis SET reads instead of a full scan of every SimHash.
+func saveSimHash(rdb *redis.Client, ctx context.Context, hashedKey, fullPath string, hash uint64) error {
+	pipe := rdb.Pipeline()
+	pipe.Set(ctx, keyPrefixSimHash+hashedKey, strconv.FormatUint(hash, 16), 0)
+	for _, bandKey := range simHashBandKeys(hash) {
+		pipe.SAdd(ctx, bandKey, fullPath)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error saving simhash for %s: %w", fullPath, err)
+	}
+	return nil
+}
+
+// ProcessFileContentSimilarity reads fullPath through fp.fs, computes its
+// SimHash, and stores it alongside the exact-match hashes ProcessFile
+// already maintains. It is separate from ProcessFile so callers that only
+// need exact dedup aren't forced to pay for a second read.
+//
+// SimHash trades precision for a fixed-size fingerprint: it's built for
+// text-like content where a shared phrase anywhere in the file should move
+// the hash closer, not for the binary/media files content_cdc.go's chunkers
+// target. main.go's -find-simhash wires this in alongside -find-similar's
+// CDC layer, since the two catch different kinds of near-duplicate.
+func (fp *FileProcessor) ProcessFileContentSimilarity(fullPath string) error {
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("error opening file for simhash: %w", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("error reading file for simhash: %w", err)
+	}
+
+	hash := computeSimHash(content)
+	hashedKey := fp.generateHashFunc(fullPath)
+	return saveSimHash(fp.Rdb, fp.Ctx, hashedKey, fullPath, hash)
+}
+
+// SimilarityPair is a near-duplicate match found by FindSimilarContent.
+type SimilarityPair struct {
+	Path1    string
+	Path2    string
+	Distance int
+}
+
+// FindSimilarContent scans every stored SimHash and, for each one, probes
+// its 4 band buckets for candidates before computing the exact Hamming
+// distance, emitting pairs within threshold (≤0 uses
+// defaultSimilarityThreshold).
+func (fp *FileProcessor) FindSimilarContent(threshold int) ([]SimilarityPair, error) {
+	if threshold <= 0 {
+		threshold = defaultSimilarityThreshold
+	}
+
+	hashes, err := fp.scanSimHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[[2]string]struct{})
+	var pairs []SimilarityPair
+
+	for path, hash := range hashes {
+		candidates, err := fp.candidateSimHashPaths(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candidatePath := range candidates {
+			if candidatePath == path {
+				continue
+			}
+			candidateHash, ok := hashes[candidatePath]
+			if !ok {
+				continue
+			}
+
+			pairKey := [2]string{path, candidatePath}
+			if pairKey[0] > pairKey[1] {
+				pairKey[0], pairKey[1] = pairKey[1], pairKey[0]
+			}
+			if _, done := seen[pairKey]; done {
+				continue
+			}
+			seen[pairKey] = struct{}{}
+
+			if d := hammingDistance64(hash, candidateHash); d <= threshold {
+				pairs = append(pairs, SimilarityPair{Path1: pairKey[0], Path2: pairKey[1], Distance: d})
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// candidateSimHashPaths returns the union of paths in hash's 4 band
+// buckets.
+func (fp *FileProcessor) candidateSimHashPaths(hash uint64) ([]string, error) {
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, bandKey := range simHashBandKeys(hash) {
+		members, err := fp.Rdb.SMembers(fp.Ctx, bandKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading simhash band %s: %w", bandKey, err)
+		}
+		for _, m := range members {
+			if _, ok := seen[m]; ok {
+				continue
+			}
+			seen[m] = struct{}{}
+			paths = append(paths, m)
+		}
+	}
+	return paths, nil
+}
+
+// scanSimHashes returns every stored path -> SimHash pair.
+func (fp *FileProcessor) scanSimHashes() (map[string]uint64, error) {
+	result := make(map[string]uint64)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixSimHash+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixSimHash)
+
+		hashStr, err := fp.Rdb.Get(fp.Ctx, iter.Val()).Result()
+		if err != nil {
+			continue
+		}
+		hash, err := strconv.ParseUint(hashStr, 16, 64)
+		if err != nil {
+			continue
+		}
+
+		path, err := fp.Rdb.Get(fp.Ctx, getHashedKeyToPathKey(hashedKey)).Result()
+		if err != nil {
+			continue
+		}
+
+		result[path] = hash
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning simhashes: %w", err)
+	}
+	return result, nil
+}
+
+// WriteSimilarFilesToFile mirrors FileProcessor.WriteDuplicateFilesToFile
+// but for near-duplicates found by FindSimilarContent, prefixing every line
+// with "[~]" rather than "[+]"/"[-]" since there's no canonical original.
+func (fp *FileProcessor) WriteSimilarFilesToFile(rootDir, outputFile string, threshold int) error {
+	pairs, err := fp.FindSimilarContent(threshold)
+	if err != nil {
+		return fmt.Errorf("error finding similar content: %w", err)
+	}
+
+	outputPath := filepath.Join(rootDir, outputFile)
+	file, err := fp.fs.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	for _, pair := range pairs {
+		line := fmt.Sprintf("Near-duplicate (distance %d):\n[~] %s\n[~] %s\n\n",
+			pair.Distance,
+			cleanRelativePath(rootDir, pair.Path1),
+			cleanRelativePath(rootDir, pair.Path2),
+		)
+		if _, err := file.WriteString(line); err != nil {
+			return fmt.Errorf("error writing similarity line: %w", err)
+		}
+	}
+
+	return nil
+}
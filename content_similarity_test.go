@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSimHashDeterministic(t *testing.T) {
+	content := []byte("the quick brown fox jumps over the lazy dog")
+	assert.Equal(t, computeSimHash(content), computeSimHash(content))
+}
+
+func TestComputeSimHashSimilarContentIsClose(t *testing.T) {
+	a := []byte("the quick brown fox jumps over the lazy dog")
+	b := []byte("the quick brown fox jumps over the lazy cat")
+	assert.LessOrEqual(t, hammingDistance64(computeSimHash(a), computeSimHash(b)), defaultSimilarityThreshold)
+}
+
+func TestHammingDistance64(t *testing.T) {
+	assert.Equal(t, 0, hammingDistance64(0xFF, 0xFF))
+	assert.Equal(t, 1, hammingDistance64(0b1000, 0b0000))
+	assert.Equal(t, 64, hammingDistance64(0, ^uint64(0)))
+}
+
+func TestFindSimilarContentUsesBandedLookupNotFullScan(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	fp := CreateFileProcessor(rdb, ctx, nil)
+
+	// Seed two known-close SimHashes and one far one, recording the path ->
+	// hashedKey -> path round trip saveFileInfoToRedis normally sets up.
+	seed := func(path string, hash uint64) {
+		hashedKey := generateHash(path)
+		require.NoError(t, rdb.Set(ctx, getHashedKeyToPathKey(hashedKey), path, 0).Err())
+		require.NoError(t, saveSimHash(rdb, ctx, hashedKey, path, hash))
+	}
+
+	seed("/a.log", 0b1010)
+	seed("/b.log", 0b1011) // 1 bit away from a.log
+	seed("/c.log", 0b0101) // far from both
+
+	pairs, err := fp.FindSimilarContent(2)
+	require.NoError(t, err)
+
+	found := false
+	for _, pair := range pairs {
+		if (pair.Path1 == "/a.log" && pair.Path2 == "/b.log") || (pair.Path1 == "/b.log" && pair.Path2 == "/a.log") {
+			found = true
+			assert.Equal(t, 1, pair.Distance)
+		}
+		assert.NotContains(t, []string{pair.Path1, pair.Path2}, "/c.log")
+	}
+	assert.True(t, found)
+}
+
+func TestWriteSimilarFilesToFile(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	fp := CreateFileProcessor(rdb, ctx, nil)
+	fp.fs = afero.NewMemMapFs()
+
+	seed := func(path string, hash uint64) {
+		hashedKey := generateHash(path)
+		require.NoError(t, rdb.Set(ctx, getHashedKeyToPathKey(hashedKey), path, 0).Err())
+		require.NoError(t, saveSimHash(rdb, ctx, hashedKey, path, hash))
+	}
+	seed("/root/a.log", 0b1010)
+	seed("/root/b.log", 0b1011)
+
+	require.NoError(t, fp.WriteSimilarFilesToFile("/root", "fav.log.similar", 2))
+
+	content, err := afero.ReadFile(fp.fs, "/root/fav.log.similar")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[~]")
+	assert.Contains(t, string(content), "Near-duplicate")
+}
@@ -1,11 +0,0 @@
-package main
-
-import (
-	"time"
-)
-
-// FileInfo holds file information
-type FileInfo struct {
-	Size    int64
-	ModTime time.Time
-}
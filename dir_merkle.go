@@ -0,0 +1,154 @@
+// dir_merkle.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+)
+
+const (
+	keyPrefixDirHash        = "dirHash:"
+	keyPrefixDirHashToPaths = "dirHashToPathSet:"
+)
+
+func getDirHashKey(hashedPath string) string {
+	return keyPrefixDirHash + hashedPath
+}
+
+func getDirHashToPathSetKey(dirHash string) string {
+	return keyPrefixDirHashToPaths + dirHash
+}
+
+// BuildDirectoryMerkleTree walks rootDir and computes a Merkle root hash for
+// every directory it contains, bottom-up: a file's node hash is its
+// existing full-file hash (see calculateFileHash), and a directory's hash is
+// SHA-256 over its children sorted by name, each contributing
+// "name\x00mode\x00childHash\x00". Every directory's hash is persisted under
+// dirHash:<hashedPath> and indexed into dirHashToPathSet:<dirHash> so
+// FindDuplicateDirectories can later group directories sharing a root
+// without re-walking the tree. It returns rootDir's own Merkle root.
+func (fp *FileProcessor) BuildDirectoryMerkleTree(rootDir string) (string, error) {
+	return fp.hashDirectory(rootDir)
+}
+
+func (fp *FileProcessor) hashDirectory(dirPath string) (string, error) {
+	entries, err := afero.ReadDir(fp.fs, dirPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading directory %s: %w", dirPath, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	h := sha256.New()
+	for _, entry := range entries {
+		childPath := filepath.Join(dirPath, entry.Name())
+
+		var childHash string
+		if entry.IsDir() {
+			childHash, err = fp.hashDirectory(childPath)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			if entry.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+			childHash, err = fp.calculateFileHashFunc(childPath, FullFileReadCmd)
+			if err != nil {
+				return "", fmt.Errorf("error hashing %s: %w", childPath, err)
+			}
+		}
+
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", entry.Name(), entry.Mode(), childHash)
+	}
+
+	dirHash := hex.EncodeToString(h.Sum(nil))
+
+	hashedPath := fp.generateHashFunc(dirPath)
+	pipe := fp.Rdb.Pipeline()
+	pipe.Set(fp.Ctx, getDirHashKey(hashedPath), dirHash, 0)
+	pipe.Set(fp.Ctx, getHashedKeyToPathKey(hashedPath), dirPath, 0)
+	pipe.SAdd(fp.Ctx, getDirHashToPathSetKey(dirHash), dirPath)
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return "", fmt.Errorf("error saving directory hash for %s: %w", dirPath, err)
+	}
+
+	return dirHash, nil
+}
+
+// DuplicateDirectoryGroup is a set of directories sharing the same Merkle
+// root, found by FindDuplicateDirectories - their contents are byte-identical
+// all the way down, so deduping the whole tree is safe.
+type DuplicateDirectoryGroup struct {
+	DirHash string
+	Paths   []string
+}
+
+// FindDuplicateDirectories scans every dirHashToPathSet:<dirHash> bucket
+// BuildDirectoryMerkleTree populated and returns the ones with more than one
+// member, i.e. whole directories that are byte-identical.
+func (fp *FileProcessor) FindDuplicateDirectories() ([]DuplicateDirectoryGroup, error) {
+	var groups []DuplicateDirectoryGroup
+
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixDirHashToPaths+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		key := iter.Val()
+		dirHash := strings.TrimPrefix(key, keyPrefixDirHashToPaths)
+
+		paths, err := fp.Rdb.SMembers(fp.Ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("error reading directory hash bucket %s: %w", dirHash, err)
+		}
+		if len(paths) > 1 {
+			groups = append(groups, DuplicateDirectoryGroup{DirHash: dirHash, Paths: paths})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning directory hash buckets: %w", err)
+	}
+
+	return groups, nil
+}
+
+// WriteDuplicateDirectoriesToFile mirrors WriteDuplicateFilesToFile but for
+// whole directories found by FindDuplicateDirectories, conventionally
+// written to "fav.log.dupdirs", so users can dedup entire trees at once
+// instead of file-by-file - a big win for photo/backup archives where
+// hundreds of files typically move together.
+func (fp *FileProcessor) WriteDuplicateDirectoriesToFile(rootDir, outputFile string) error {
+	groups, err := fp.FindDuplicateDirectories()
+	if err != nil {
+		return fmt.Errorf("error finding duplicate directories: %w", err)
+	}
+
+	outputPath := filepath.Join(rootDir, outputFile)
+	return fp.atomicWrite(outputPath, func(w io.Writer) error {
+		for _, group := range groups {
+			if _, err := fmt.Fprintf(w, "Duplicate directories for dirHash %s:\n", group.DirHash); err != nil {
+				return err
+			}
+			for i, path := range group.Paths {
+				prefix := "[-]"
+				if i == 0 {
+					prefix = "[+]"
+				}
+				if _, err := fmt.Fprintf(w, "%s %s\n", prefix, cleanRelativePath(rootDir, path)); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_BuildDirectoryMerkleTree(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root/a/sub", 0755))
+	require.NoError(t, fs.MkdirAll("/root/b/sub", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/a/sub/file.txt", []byte("same content"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/b/sub/file.txt", []byte("same content"), 0644))
+
+	hashA, err := fp.BuildDirectoryMerkleTree("/root/a")
+	require.NoError(t, err)
+	hashB, err := fp.BuildDirectoryMerkleTree("/root/b")
+	require.NoError(t, err)
+
+	assert.Equal(t, hashA, hashB)
+
+	hashedPath := generateHash("/root/a/sub")
+	storedHash, err := fp.Rdb.Get(fp.Ctx, getDirHashKey(hashedPath)).Result()
+	require.NoError(t, err)
+	assert.NotEmpty(t, storedHash)
+}
+
+func TestFileProcessor_BuildDirectoryMerkleTree_DifferentContentDiffers(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root/a", 0755))
+	require.NoError(t, fs.MkdirAll("/root/b", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/a/file.txt", []byte("content one"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/b/file.txt", []byte("content two"), 0644))
+
+	hashA, err := fp.BuildDirectoryMerkleTree("/root/a")
+	require.NoError(t, err)
+	hashB, err := fp.BuildDirectoryMerkleTree("/root/b")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, hashA, hashB)
+}
+
+func TestFileProcessor_FindDuplicateDirectories(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root/a", 0755))
+	require.NoError(t, fs.MkdirAll("/root/b", 0755))
+	require.NoError(t, fs.MkdirAll("/root/c", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/a/file.txt", []byte("dup"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/b/file.txt", []byte("dup"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/c/file.txt", []byte("unique"), 0644))
+
+	_, err := fp.BuildDirectoryMerkleTree("/root/a")
+	require.NoError(t, err)
+	_, err = fp.BuildDirectoryMerkleTree("/root/b")
+	require.NoError(t, err)
+	_, err = fp.BuildDirectoryMerkleTree("/root/c")
+	require.NoError(t, err)
+
+	groups, err := fp.FindDuplicateDirectories()
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.ElementsMatch(t, []string{"/root/a", "/root/b"}, groups[0].Paths)
+}
+
+func TestFileProcessor_WriteDuplicateDirectoriesToFile(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root/a", 0755))
+	require.NoError(t, fs.MkdirAll("/root/b", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/a/file.txt", []byte("dup"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/b/file.txt", []byte("dup"), 0644))
+
+	_, err := fp.BuildDirectoryMerkleTree("/root/a")
+	require.NoError(t, err)
+	_, err = fp.BuildDirectoryMerkleTree("/root/b")
+	require.NoError(t, err)
+
+	require.NoError(t, fp.WriteDuplicateDirectoriesToFile("/root", "fav.log.dupdirs"))
+
+	content, err := afero.ReadFile(fs, "/root/fav.log.dupdirs")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Duplicate directories for dirHash")
+	assert.Contains(t, string(content), "[+]")
+	assert.Contains(t, string(content), "[-]")
+}
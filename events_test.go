@@ -0,0 +1,131 @@
+// events_test.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeEvents parses buf as newline-delimited JSON Events, in order.
+func decodeEvents(t *testing.T, buf *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		require.NoError(t, json.Unmarshal(line, &e))
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func eventsOfType(events []Event, eventType string) []Event {
+	var out []Event
+	for _, e := range events {
+		if e.Type == eventType {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestJSONLSinkProcessFileEventOrderingAndFields(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	var buf bytes.Buffer
+	fp.EventSink = NewJSONLSink(&buf)
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644))
+	require.NoError(t, fp.ProcessFile("/", "a.txt", true))
+
+	events := decodeEvents(t, &buf)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, EventHashComputed, events[0].Type)
+	assert.Equal(t, "/a.txt", events[0].Path)
+	assert.NotEmpty(t, events[0].PartialHash)
+
+	assert.Equal(t, EventFileProcessed, events[1].Type)
+	assert.Equal(t, "/a.txt", events[1].Path)
+	assert.Equal(t, int64(len("hello world")), events[1].Size)
+	require.NotNil(t, events[1].ModTime)
+	assert.NotEmpty(t, events[1].PartialHash)
+	assert.GreaterOrEqual(t, events[1].ElapsedMs, int64(0))
+}
+
+func TestJSONLSinkDuplicateGroupEvents(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("same"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("same"), 0644))
+	require.NoError(t, fp.ProcessFile("/", "a.txt", true))
+	require.NoError(t, fp.ProcessFile("/", "b.txt", true))
+
+	var buf bytes.Buffer
+	fp.EventSink = NewJSONLSink(&buf)
+
+	fullHash := "fullhash-same"
+	require.NoError(t, fp.SaveDuplicateFileInfoToRedis(fullHash, FileInfo{Path: "/a.txt", Size: 4, ModTime: time.Now()}))
+	require.NoError(t, fp.SaveDuplicateFileInfoToRedis(fullHash, FileInfo{Path: "/b.txt", Size: 4, ModTime: time.Now()}))
+
+	events := decodeEvents(t, &buf)
+	groups := eventsOfType(events, EventDuplicateGroup)
+	require.Len(t, groups, 2)
+	assert.Equal(t, fullHash, groups[0].GroupID)
+	assert.Equal(t, "/a.txt", groups[0].Path)
+	assert.Equal(t, fullHash, groups[1].GroupID)
+	assert.Equal(t, "/b.txt", groups[1].Path)
+
+	buf.Reset()
+	require.NoError(t, fp.WriteDuplicateFilesToFile("/", "duplicates.txt", fp.Rdb, fp.Ctx))
+
+	writeEvents := decodeEvents(t, &buf)
+	writeGroups := eventsOfType(writeEvents, EventDuplicateGroup)
+	require.Len(t, writeGroups, 1)
+	assert.Equal(t, fullHash, writeGroups[0].GroupID)
+}
+
+func TestJSONLSinkPruneEvictedEvent(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/media/old.txt", 100, now.Add(-48*time.Hour), now.Add(-48*time.Hour), "oldhash", "")
+
+	var buf bytes.Buffer
+	fp.EventSink = NewJSONLSink(&buf)
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{TTL: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TTLEvicted)
+
+	events := decodeEvents(t, &buf)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventPruneEvicted, events[0].Type)
+	assert.Equal(t, "/media/old.txt", events[0].Path)
+	assert.Equal(t, int64(100), events[0].Size)
+}
+
+func TestNilEventSinkIsNoOp(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.Nil(t, fp.EventSink)
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello"), 0644))
+	assert.NoError(t, fp.ProcessFile("/", "a.txt", true))
+}
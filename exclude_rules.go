@@ -0,0 +1,113 @@
+// exclude_rules.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// excludeMatcher reports whether a rule matches path, given whether path is
+// a directory.
+type excludeMatcher func(path string, isDir bool) bool
+
+// excludeRule is one compiled line from an exclude pattern file. negate
+// marks a "!"-prefixed rule that re-includes a path an earlier rule
+// excluded, mirroring .gitignore/.stignore rule resolution.
+type excludeRule struct {
+	negate  bool
+	matcher excludeMatcher
+}
+
+// ExcludeRuleSet is an ordered list of exclude rules compiled by
+// CompileExcludeRules. Unlike the flat "any pattern matches -> exclude"
+// behavior it replaces, ShouldExclude evaluates every rule in file order and
+// returns the last one that matched, so a later "!" rule can re-include a
+// path an earlier glob excluded - the same resolution .gitignore/.stignore
+// use.
+type ExcludeRuleSet struct {
+	rules []excludeRule
+}
+
+// CompileExcludeRules compiles exclude-pattern-file lines into an
+// ExcludeRuleSet. Each line is one of:
+//   - blank, or starting with "#": ignored
+//   - "re:<pattern>": <pattern> is compiled as an unanchored Go regular
+//     expression, the same behavior every line had before this syntax
+//     existed
+//   - otherwise a .gitignore-style glob: a leading "!" negates the rule
+//     (re-including a path an earlier rule excluded), a leading "/" anchors
+//     the pattern to the scan root, "**" matches any number of path
+//     components, "*"/"?" are confined to one component, and a trailing "/"
+//     restricts the rule to directories
+func CompileExcludeRules(lines []string) (*ExcludeRuleSet, error) {
+	rs := &ExcludeRuleSet{}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(trimmed, "re:"); ok {
+			re, err := regexp.Compile(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex pattern %q: %w", rest, err)
+			}
+			rs.rules = append(rs.rules, excludeRule{
+				matcher: func(path string, isDir bool) bool { return re.MatchString(path) },
+			})
+			continue
+		}
+
+		pattern := trimmed
+		dirOnly := strings.HasSuffix(pattern, "/") && pattern != "/"
+		if dirOnly {
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		re, negate, err := gitignoreToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		rs.rules = append(rs.rules, excludeRule{
+			negate: negate,
+			matcher: func(path string, isDir bool) bool {
+				if dirOnly && !isDir {
+					return false
+				}
+				return re.MatchString(path)
+			},
+		})
+	}
+	return rs, nil
+}
+
+// ShouldExclude reports whether path is excluded per rs: every rule runs in
+// order and the last one to match wins, so a later "!" rule can re-include a
+// path an earlier rule excluded. No rule matching means include, the same
+// default .gitignore uses. A nil ExcludeRuleSet excludes nothing.
+func (rs *ExcludeRuleSet) ShouldExclude(path string, isDir bool) bool {
+	if rs == nil {
+		return false
+	}
+	excluded := false
+	for _, rule := range rs.rules {
+		if rule.matcher(path, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// addRawPattern appends pattern to rs as a raw regular expression rule,
+// compiled the same way a "re:" line is, for callers building a ruleset one
+// pattern at a time (see FileProcessor.AddExcludePattern).
+func (rs *ExcludeRuleSet) addRawPattern(pattern string) error {
+	compiled, err := CompileExcludeRules([]string{"re:" + pattern})
+	if err != nil {
+		return err
+	}
+	rs.rules = append(rs.rules, compiled.rules...)
+	return nil
+}
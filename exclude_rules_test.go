@@ -0,0 +1,61 @@
+// exclude_rules_test.go
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcludeRuleSetNegationReincludes(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{"*.log", "!important.log"})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("debug.log", false))
+	assert.False(t, rs.ShouldExclude("important.log", false), "later negation should re-include")
+}
+
+func TestExcludeRuleSetLastMatchWins(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{"!*.log", "*.log"})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("debug.log", false), "later rule should override the earlier negation")
+}
+
+func TestExcludeRuleSetAnchoring(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{"/root.txt"})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("root.txt", false))
+	assert.False(t, rs.ShouldExclude("dir/root.txt", false), "anchored pattern should not match in a subdirectory")
+}
+
+func TestExcludeRuleSetDirectoryOnly(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{"cache/"})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("cache", true))
+	assert.False(t, rs.ShouldExclude("cache", false), "trailing slash should restrict the rule to directories")
+}
+
+func TestExcludeRuleSetRawRegexEscapeHatch(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{`re:^/mnt/.*\.tmp$`})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("/mnt/work/file.tmp", false))
+	assert.False(t, rs.ShouldExclude("/mnt/work/file.txt", false))
+}
+
+func TestExcludeRuleSetBlankAndCommentLinesIgnored(t *testing.T) {
+	rs, err := CompileExcludeRules([]string{"", "   ", "# comment", "*.tmp"})
+	require.NoError(t, err)
+
+	assert.True(t, rs.ShouldExclude("file.tmp", false))
+	assert.False(t, rs.ShouldExclude("file.txt", false))
+}
+
+func TestExcludeRuleSetNilIsSafe(t *testing.T) {
+	var rs *ExcludeRuleSet
+	assert.False(t, rs.ShouldExclude("anything", false))
+}
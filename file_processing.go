@@ -4,7 +4,6 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/sha512"
 	"encoding/gob"
 	"fmt"
 	"github.com/go-redis/redis/v8"
@@ -27,15 +26,101 @@ type FileProcessor struct {
 	calculateFileHashFunc   func(path string, limit int64) (string, error)
 	saveFileInfoToRedisFunc func(*redis.Client, context.Context, string, FileInfo, string, string, bool) error
 	fs                      afero.Fs
-	excludeRegexps          []*regexp.Regexp
+	// KeepRotations is how many historical "<path>.old[.N]" snapshots
+	// atomicWrite keeps around a written output file. VerifyAfterWrite makes
+	// atomicWrite re-open and checksum the file after renaming it into place.
+	KeepRotations    int
+	VerifyAfterWrite bool
+	excludeRules     *ExcludeRuleSet
+	// includeRegexps restricts scanning to paths matching at least one
+	// pattern; an empty slice means "match everything". includeNegateRegexps
+	// holds patterns prefixed with "!" that re-exclude an otherwise included
+	// path, mirroring .gitignore negation semantics.
+	includeRegexps       []*regexp.Regexp
+	includeNegateRegexps []*regexp.Regexp
+
+	// CDCAvgChunkSize, CDCMinChunkSize, and CDCMaxChunkSize override the
+	// content-defined-chunking target sizes ProcessFileCDC uses; zero means
+	// "use the package default" (see defaultCDCAvgChunkSize and friends).
+	CDCAvgChunkSize int
+	CDCMinChunkSize int
+	CDCMaxChunkSize int
+
+	// RollingAvgChunkSize, RollingMinChunkSize, and RollingMaxChunkSize
+	// override the Rabin rolling-hash chunk sizes ProcessFileRollingChunks
+	// (see content_rolling_chunk.go) targets; zero means "use the package
+	// default" (see defaultRollingAvgChunkSize and friends). Unlike the
+	// gear-hash chunks ProcessFileCDC computes, these are stored in Redis
+	// sets keyed for FindSimilar's SINTERSTORE-driven lookup rather than as a
+	// single gob blob.
+	RollingAvgChunkSize int
+	RollingMinChunkSize int
+	RollingMaxChunkSize int
+
+	// EventSink, when set, receives a structured Event for each notable step
+	// ProcessFile, SaveDuplicateFileInfoToRedis, and WriteDuplicateFilesToFile
+	// take (see events.go). A nil EventSink (the default) disables emission
+	// entirely, so existing callers see no behavior change.
+	EventSink EventSink
+
+	// HeadHasher and FullHasher select the Hasher (see hash_algorithms.go)
+	// calculateFileHash uses for, respectively, the ReadLimit head-hash tier
+	// and the FullFileReadCmd full-hash tier. Nil means SHA512Hasher, matching
+	// calculateFileHash's behavior before per-tier hashers existed.
+	HeadHasher Hasher
+	FullHasher Hasher
+
+	// Algorithms, when non-empty, is the set of hash algorithms
+	// ProcessFileMultiHash (see multi_hash.go) computes for each file in a
+	// single pass, enabling cross-verification across algorithms rather than
+	// trusting calculateFileHash's single configured Hasher.
+	Algorithms []string
+
+	// HashCacheMode controls which of calculateFileHash's two tiers
+	// (partial/ReadLimit and full/FullFileReadCmd) persist their result to
+	// Redis, and whether atime:<hashedKey> is refreshed on every read or only
+	// on writes (see hash_cache_mode.go). The zero value, HashCacheFull,
+	// matches calculateFileHash's behavior before HashCacheMode existed.
+	HashCacheMode HashCacheMode
+
+	// HeadSampleSize overrides how many bytes ProcessFileTiered's head-sample
+	// stage reads from each end of a file (see tiered_duplicates.go); zero
+	// means defaultHeadSampleSize.
+	HeadSampleSize int64
+
+	// Metrics, when set, receives counters and phase timings as ProcessFile
+	// and calculateFileHash run (see metrics.go). A nil Metrics (the
+	// default) disables recording entirely; every Metrics method is a no-op
+	// on a nil receiver, so call sites don't need their own nil check.
+	Metrics *Metrics
+
+	// Caches holds the named, disk-backed caches (see filecache.go).
+	// calculateFileHash consults "partialhash"/"fullhash" before touching
+	// Redis; ProcessFile writes each FileInfo it saves into "fileinfo" after
+	// the Redis write, and nothing reads "fileinfo" back. A nil
+	// Caches (the default) means every lookup falls straight through to
+	// Redis, matching behavior before filecache existed.
+	Caches *Caches
+
+	// HashedKeyLRU and FileInfoLRU are in-process, byte-bounded LRU caches
+	// (see lru_cache.go) consulted before any Redis round trip for,
+	// respectively, short strings (path->hashedKey, hashedKey->partial/full
+	// hash) and larger gob-encoded FileInfo blobs. Nil (the default)
+	// disables them - every lookup goes straight to Redis, matching
+	// behavior before these caches existed. Unlike Caches, which survives
+	// across runs on disk, these only cover repeat lookups within the same
+	// process.
+	HashedKeyLRU LRUCache
+	FileInfoLRU  LRUCache
 }
 
-func CreateFileProcessor(rdb *redis.Client, ctx context.Context, excludeRegexps []*regexp.Regexp, options ...func(*FileProcessor)) *FileProcessor {
+func CreateFileProcessor(rdb *redis.Client, ctx context.Context, excludeRules *ExcludeRuleSet, options ...func(*FileProcessor)) *FileProcessor {
 	fp := &FileProcessor{
-		Rdb:            rdb,
-		Ctx:            ctx,
-		fs:             afero.NewOsFs(),
-		excludeRegexps: excludeRegexps,
+		Rdb:           rdb,
+		Ctx:           ctx,
+		fs:            afero.NewOsFs(),
+		KeepRotations: defaultKeepRotations,
+		excludeRules:  excludeRules,
 	}
 
 	// 设置默认值
@@ -51,6 +136,130 @@ func CreateFileProcessor(rdb *redis.Client, ctx context.Context, excludeRegexps
 	return fp
 }
 
+// WithFileCaches is a CreateFileProcessor option that attaches a set of
+// named local disk caches (see filecache.go) in front of Redis.
+func WithFileCaches(caches *Caches) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.Caches = caches }
+}
+
+// WithLRUCaches is a CreateFileProcessor option that attaches in-process
+// LRU caches (see lru_cache.go) in front of Redis for hashedKey/hash
+// lookups and FileInfo reads.
+func WithLRUCaches(hashedKeyLRU, fileInfoLRU LRUCache) func(*FileProcessor) {
+	return func(fp *FileProcessor) {
+		fp.HashedKeyLRU = hashedKeyLRU
+		fp.FileInfoLRU = fileInfoLRU
+	}
+}
+
+// WithIncludePatterns is a CreateFileProcessor option that seeds the
+// processor's IncludePatterns from a list of .gitignore-style globs.
+func WithIncludePatterns(patterns []string) func(*FileProcessor) {
+	return func(fp *FileProcessor) {
+		for _, pattern := range patterns {
+			if err := fp.AddIncludePattern(pattern); err != nil {
+				log.Printf("Error adding include pattern %q: %v", pattern, err)
+			}
+		}
+	}
+}
+
+// WithFilesystem overrides the afero.Fs FileProcessor reads and writes
+// through, letting callers point a scan at an in-memory fs for tests or, via
+// fsregistry, at an S3/GCS/SFTP root instead of the local OS filesystem.
+func WithFilesystem(fs afero.Fs) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.fs = fs }
+}
+
+// WithKeepRotations overrides how many historical "<path>.old[.N]" snapshots
+// atomicWrite retains for each output file it writes.
+func WithKeepRotations(n int) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.KeepRotations = n }
+}
+
+// WithVerifyAfterWrite makes atomicWrite re-open and checksum a file after
+// renaming it into place, catching a filesystem that silently truncated or
+// corrupted the write.
+func WithVerifyAfterWrite(verify bool) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.VerifyAfterWrite = verify }
+}
+
+// WithCDCChunkSizes overrides the average/min/max chunk sizes
+// ProcessFileCDC's rolling hash targets; zero leaves the package default for
+// that size in place.
+func WithCDCChunkSizes(avg, min, max int) func(*FileProcessor) {
+	return func(fp *FileProcessor) {
+		fp.CDCAvgChunkSize = avg
+		fp.CDCMinChunkSize = min
+		fp.CDCMaxChunkSize = max
+	}
+}
+
+// WithRollingChunkSizes overrides the average/min/max chunk sizes
+// ProcessFileRollingChunks's Rabin rolling hash targets; zero leaves the
+// package default for that size in place.
+func WithRollingChunkSizes(avg, min, max int) func(*FileProcessor) {
+	return func(fp *FileProcessor) {
+		fp.RollingAvgChunkSize = avg
+		fp.RollingMinChunkSize = min
+		fp.RollingMaxChunkSize = max
+	}
+}
+
+// WithEventSink wires fp to emit structured Events (see events.go) as
+// ProcessFile, SaveDuplicateFileInfoToRedis, WriteDuplicateFilesToFile, and
+// Prune run. The default, an unset EventSink, keeps fp's behavior identical
+// to before events.go existed.
+func WithEventSink(sink EventSink) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.EventSink = sink }
+}
+
+// WithHeadHasher overrides the Hasher calculateFileHash uses for the
+// ReadLimit head-hash tier, e.g. WithHeadHasher(XXHash64Hasher) to trade
+// cryptographic strength for speed on the tier that runs against every file.
+func WithHeadHasher(h Hasher) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.HeadHasher = h }
+}
+
+// WithFullHasher overrides the Hasher calculateFileHash uses for the
+// FullFileReadCmd full-hash tier, e.g. WithFullHasher(BLAKE3Hasher).
+func WithFullHasher(h Hasher) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.FullHasher = h }
+}
+
+// WithHashAlgorithms configures the set of algorithms ProcessFileMultiHash
+// computes together for each file, e.g.
+// WithHashAlgorithms("md5", "sha256", "blake3").
+func WithHashAlgorithms(algorithms ...string) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.Algorithms = algorithms }
+}
+
+// WithMetrics wires fp to record counters and phase timings into m as
+// ProcessFile and calculateFileHash run (see metrics.go).
+func WithMetrics(m *Metrics) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.Metrics = m }
+}
+
+// hasherFor returns the Hasher calculateFileHash should use for limit,
+// falling back to SHA512Hasher when the relevant tier has no override.
+func (fp *FileProcessor) hasherFor(limit int64) Hasher {
+	if limit == FullFileReadCmd {
+		if fp.FullHasher != nil {
+			return fp.FullHasher
+		}
+	} else if fp.HeadHasher != nil {
+		return fp.HeadHasher
+	}
+	return SHA512Hasher
+}
+
+// atomicWrite writes through write using the write-tmp/rotate-old/rename
+// pattern in atomicWriteFile, honoring fp.KeepRotations and
+// fp.VerifyAfterWrite.
+func (fp *FileProcessor) atomicWrite(path string, write func(io.Writer) error) error {
+	return atomicWriteFile(fp.fs, path, fp.KeepRotations, fp.VerifyAfterWrite, write)
+}
+
 // 修改 saveToFile 方法
 func (fp *FileProcessor) saveToFile(rootDir, filename string, sortByModTime bool) error {
 	outputPath := filepath.Join(rootDir, filename)
@@ -64,12 +273,6 @@ func (fp *FileProcessor) saveToFile(rootDir, filename string, sortByModTime bool
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	file, err := fp.fs.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("error creating file: %w", err)
-	}
-	defer file.Close()
-
 	iter := fp.Rdb.Scan(fp.Ctx, 0, "fileInfo:*", 0).Iterator()
 	data := make(map[string]FileInfo)
 
@@ -102,13 +305,19 @@ func (fp *FileProcessor) saveToFile(rootDir, filename string, sortByModTime bool
 
 	sortKeys(keys, data, sortByModTime)
 
-	for _, k := range keys {
-		fileInfo := data[k]
-		cleanedPath := cleanRelativePath(rootDir, k)
-		line := formatFileInfoLine(fileInfo, cleanedPath, sortByModTime)
-		if _, err := file.WriteString(line); err != nil {
-			return fmt.Errorf("error writing to file: %w", err)
+	err = fp.atomicWrite(outputPath, func(w io.Writer) error {
+		for _, k := range keys {
+			fileInfo := data[k]
+			cleanedPath := cleanRelativePath(rootDir, k)
+			line := formatFileInfoLine(fileInfo, cleanedPath, sortByModTime)
+			if _, err := io.WriteString(w, line); err != nil {
+				return fmt.Errorf("error writing to file: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("File updated successfully: %s", absOutputPath)
@@ -135,25 +344,46 @@ const (
 	FullFileReadCmd = -1
 )
 
-func (fp *FileProcessor) ProcessFile(rootDir, relativePath string) error {
+// ProcessFile stats and records fullPath's FileInfo. When calculateHashes is
+// false (plain scan/info mode, no duplicate flags set - see main.go's
+// calculateHashes derivation) it skips the partial-hash read and the
+// fileHashToPathSet membership entirely, since nothing downstream needs them
+// without --find-duplicates/--output-duplicates/--delete-duplicates.
+func (fp *FileProcessor) ProcessFile(rootDir, relativePath string, calculateHashes bool) error {
+	start := time.Now()
 	fullPath := filepath.Join(rootDir, relativePath)
 	log.Printf("Processing file: %s", fullPath)
+	fp.Metrics.IncFilesConsidered()
 
 	info, err := fp.fs.Stat(fullPath)
 	if err != nil {
+		fp.emit(Event{Type: EventError, Path: fullPath, Error: err.Error()})
 		return fmt.Errorf("error getting file info: %w", err)
 	}
 
-	// 计算部分哈希
-	fileHash, err := fp.calculateFileHashFunc(fullPath, ReadLimit)
-	if err != nil {
-		return fmt.Errorf("error calculating file hash: %w", err)
-	}
+	var fileHash, fullHash string
+	if calculateHashes {
+		// 计算部分哈希
+		fileHash, err = fp.calculateFileHashFunc(fullPath, ReadLimit)
+		if err != nil {
+			fp.emit(Event{Type: EventError, Path: fullPath, Error: err.Error()})
+			return fmt.Errorf("error calculating file hash: %w", err)
+		}
 
-	// 将文件路径添加到部分哈希集合
-	err = fp.Rdb.SAdd(fp.Ctx, "fileHashToPathSet:"+fileHash, fullPath).Err()
-	if err != nil {
-		return fmt.Errorf("error adding path to hash set: %w", err)
+		// 计算完整哈希，供 hashedKeyToFullHash 及后续精确查重使用
+		fullHash, err = fp.calculateFileHashFunc(fullPath, FullFileReadCmd)
+		if err != nil {
+			fp.emit(Event{Type: EventError, Path: fullPath, Error: err.Error()})
+			return fmt.Errorf("error calculating full file hash: %w", err)
+		}
+		fp.emit(Event{Type: EventHashComputed, Path: fullPath, PartialHash: fileHash, FullHash: fullHash})
+
+		// 将文件路径添加到部分哈希集合
+		err = fp.Rdb.SAdd(fp.Ctx, "fileHashToPathSet:"+fileHash, fullPath).Err()
+		if err != nil {
+			fp.emit(Event{Type: EventError, Path: fullPath, Error: err.Error()})
+			return fmt.Errorf("error adding path to hash set: %w", err)
+		}
 	}
 
 	// 保存文件信息到 Redis
@@ -164,12 +394,41 @@ func (fp *FileProcessor) ProcessFile(rootDir, relativePath string) error {
 	}
 
 	// 调用原有的 saveFileInfoToRedis 方法，保持其签名不变
-	// 传入空的 fullHash，并设置 calculateHashes 为 true 表示需要计算哈希
-	err = saveFileInfoToRedis(fp.Rdb, fp.Ctx, fullPath, fileInfo, fileHash, "", true)
+	err = saveFileInfoToRedis(fp.Rdb, fp.Ctx, fullPath, fileInfo, fileHash, fullHash, calculateHashes)
 	if err != nil {
+		fp.emit(Event{Type: EventError, Path: fullPath, Error: err.Error()})
 		return fmt.Errorf("error saving file info to Redis: %w", err)
 	}
 
+	// 将 FileInfo 也写入本地 fileinfo filecache（见 filecache.go），这样 Redis
+	// 被清空后重新运行仍能复用上一次扫描的结果。
+	if cache := fp.Caches.Get("fileinfo"); cache.Enabled() {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(fileInfo); err != nil {
+			log.Printf("Warning: failed to encode filecache entry for %s: %v", fullPath, err)
+		} else if err := cache.Put(fullPath, buf.Bytes()); err != nil {
+			log.Printf("Warning: failed to write filecache entry for %s: %v", fullPath, err)
+		}
+	}
+
+	// lastSeen records when this entry was last touched, independent of
+	// ModTime, so Pruner's TTL policy can also expire entries whose files
+	// haven't been revisited in a while even if their ModTime is recent.
+	hashedKey := fp.generateHashFunc(fullPath)
+	if err := fp.Rdb.Set(fp.Ctx, getLastSeenKey(hashedKey), time.Now().Unix(), 0).Err(); err != nil {
+		log.Printf("Warning: failed to update lastSeen for %s: %v", fullPath, err)
+	}
+
+	modTime := fileInfo.ModTime
+	fp.emit(Event{
+		Type:        EventFileProcessed,
+		Path:        fullPath,
+		Size:        fileInfo.Size,
+		ModTime:     &modTime,
+		PartialHash: fileHash,
+		ElapsedMs:   time.Since(start).Milliseconds(),
+	})
+
 	return nil
 }
 
@@ -184,62 +443,70 @@ func (fp *FileProcessor) WriteDuplicateFilesToFile(rootDir string, outputFile st
 		return fmt.Errorf("error getting absolute path: %w", err)
 	}
 
-	file, err := fp.fs.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("Error creating output file: %s", err)
-	}
-	defer file.Close()
-
-	iter := rdb.Scan(ctx, 0, "duplicateFiles:*", 0).Iterator()
-	for iter.Next(ctx) {
-		duplicateFilesKey := iter.Val()
-		fullHash := strings.TrimPrefix(duplicateFilesKey, "duplicateFiles:")
-		duplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
-		if err != nil {
-			log.Printf("Error getting duplicate files for key %s: %v", duplicateFilesKey, err)
-			continue
-		}
-
-		if len(duplicateFiles) > 1 {
-			fmt.Fprintf(file, "Duplicate files for fullHash %s:\n", fullHash)
-			for i, duplicateFile := range duplicateFiles {
-				hashedKey, err := rdb.Get(ctx, "pathToHashedKey:"+duplicateFile).Result()
-				if err != nil {
-					log.Printf("Error getting hashed key for path %s: %v", duplicateFile, err)
-					continue
-				}
-
-				fileInfoData, err := rdb.Get(ctx, "fileInfo:"+hashedKey).Bytes()
-				if err != nil {
-					log.Printf("Error getting file info for key %s: %v", hashedKey, err)
-					continue
-				}
-
-				var fileInfo FileInfo
-				err = gob.NewDecoder(bytes.NewReader(fileInfoData)).Decode(&fileInfo)
-				if err != nil {
-					log.Printf("Error decoding file info: %v", err)
-					continue
-				}
+	err = fp.atomicWrite(outputPath, func(file io.Writer) error {
+		iter := rdb.Scan(ctx, 0, "duplicateFiles:*", 0).Iterator()
+		for iter.Next(ctx) {
+			duplicateFilesKey := iter.Val()
+			fullHash := strings.TrimPrefix(duplicateFilesKey, "duplicateFiles:")
+			allDuplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
+			if err != nil {
+				log.Printf("Error getting duplicate files for key %s: %v", duplicateFilesKey, err)
+				continue
+			}
 
-				cleanedPath := cleanRelativePath(rootDir, duplicateFile)
-				formattedLine := formatFileInfoLine(fileInfo, cleanedPath, false)
-				prefix := "[-]"
-				if i == 0 {
-					prefix = "[+]"
+			duplicateFiles := make([]string, 0, len(allDuplicateFiles))
+			for _, path := range allDuplicateFiles {
+				if !fp.ShouldExclude(path) {
+					duplicateFiles = append(duplicateFiles, path)
 				}
-				line := fmt.Sprintf("%s %s", prefix, formattedLine)
+			}
 
-				if _, err := file.WriteString(line); err != nil {
-					log.Printf("Error writing line: %v", err)
+			if len(duplicateFiles) > 1 {
+				fp.emit(Event{Type: EventDuplicateGroup, GroupID: fullHash, Path: duplicateFiles[0], Kind: "exact"})
+				fmt.Fprintf(file, "Duplicate files for fullHash %s:\n", fullHash)
+				for i, duplicateFile := range duplicateFiles {
+					hashedKey, err := rdb.Get(ctx, "pathToHashedKey:"+duplicateFile).Result()
+					if err != nil {
+						log.Printf("Error getting hashed key for path %s: %v", duplicateFile, err)
+						continue
+					}
+
+					fileInfoData, err := rdb.Get(ctx, "fileInfo:"+hashedKey).Bytes()
+					if err != nil {
+						log.Printf("Error getting file info for key %s: %v", hashedKey, err)
+						continue
+					}
+
+					var fileInfo FileInfo
+					err = gob.NewDecoder(bytes.NewReader(fileInfoData)).Decode(&fileInfo)
+					if err != nil {
+						log.Printf("Error decoding file info: %v", err)
+						continue
+					}
+
+					cleanedPath := cleanRelativePath(rootDir, duplicateFile)
+					formattedLine := formatFileInfoLine(fileInfo, cleanedPath, false)
+					prefix := "[-]"
+					if i == 0 {
+						prefix = "[+]"
+					}
+					line := fmt.Sprintf("%s %s", prefix, formattedLine)
+
+					if _, err := io.WriteString(file, line); err != nil {
+						log.Printf("Error writing line: %v", err)
+					}
 				}
+				io.WriteString(file, "\n")
 			}
-			file.WriteString("\n")
 		}
-	}
 
-	if err := iter.Err(); err != nil {
-		return fmt.Errorf("error during iteration: %w", err)
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("error during iteration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Duplicate files written successfully: %s", absOutputPath)
@@ -247,12 +514,76 @@ func (fp *FileProcessor) WriteDuplicateFilesToFile(rootDir string, outputFile st
 }
 
 func (fp *FileProcessor) ShouldExclude(path string) bool {
-	for _, re := range fp.excludeRegexps {
+	isDir := false
+	if fp.fs != nil {
+		if info, err := fp.fs.Stat(path); err == nil {
+			isDir = info.IsDir()
+		}
+	}
+	return fp.ShouldExcludeInfo(path, isDir)
+}
+
+// ShouldExcludeInfo is ShouldExclude for callers that already know whether
+// path is a directory (e.g. walkFiles, already holding an os.FileInfo from
+// filepath.Walk), sparing them the redundant Stat ShouldExclude otherwise
+// does itself.
+func (fp *FileProcessor) ShouldExcludeInfo(path string, isDir bool) bool {
+	if !fp.shouldInclude(path) {
+		return true
+	}
+	return fp.excludeRules.ShouldExclude(path, isDir)
+}
+
+// shouldInclude reports whether path passes the IncludePatterns filter.
+// An empty include set matches everything. Include is evaluated before
+// exclude so that IncludePatterns can narrow the scan and ExcludePatterns
+// can still carve out exceptions within it.
+func (fp *FileProcessor) shouldInclude(path string) bool {
+	if len(fp.includeRegexps) > 0 {
+		matched := false
+		for _, re := range fp.includeRegexps {
+			if re.MatchString(path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, re := range fp.includeNegateRegexps {
 		if re.MatchString(path) {
-			return true
+			return false
 		}
 	}
-	return false
+
+	return true
+}
+
+// AddIncludePattern adds a .gitignore-style glob pattern (supporting "**"
+// and "!" negation) to the processor's IncludePatterns.
+func (fp *FileProcessor) AddIncludePattern(pattern string) error {
+	re, negate, err := gitignoreToRegexp(pattern)
+	if err != nil {
+		return err
+	}
+	if negate {
+		fp.includeNegateRegexps = append(fp.includeNegateRegexps, re)
+	} else {
+		fp.includeRegexps = append(fp.includeRegexps, re)
+	}
+	return nil
+}
+
+// AddExcludePattern adds a raw regular expression to the processor's
+// exclude rules, matching the convention used by compileExcludePatterns's
+// "re:" lines.
+func (fp *FileProcessor) AddExcludePattern(pattern string) error {
+	if fp.excludeRules == nil {
+		fp.excludeRules = &ExcludeRuleSet{}
+	}
+	return fp.excludeRules.addRawPattern(pattern)
 }
 
 type RedisFileInfoRetriever struct {
@@ -262,10 +593,24 @@ type RedisFileInfoRetriever struct {
 
 func (fp *FileProcessor) getFileInfoFromRedis(hashedKey string) (FileInfo, error) {
 	var fileInfo FileInfo
-	value, err := fp.Rdb.Get(fp.Ctx, "fileInfo:"+hashedKey).Bytes()
+
+	cacheKey := getFileInfoKey(hashedKey)
+	if fp.FileInfoLRU != nil {
+		if obj, ok := fp.FileInfoLRU.Get(cacheKey); ok {
+			if err := gob.NewDecoder(bytes.NewReader([]byte(obj.(bufferValue)))).Decode(&fileInfo); err != nil {
+				return fileInfo, fmt.Errorf("error decoding file info: %w", err)
+			}
+			return fileInfo, nil
+		}
+	}
+
+	value, err := fp.Rdb.Get(fp.Ctx, cacheKey).Bytes()
 	if err != nil {
 		return fileInfo, err
 	}
+	if fp.FileInfoLRU != nil {
+		fp.FileInfoLRU.Put(cacheKey, bufferValue(value))
+	}
 
 	buf := bytes.NewBuffer(value)
 	dec := gob.NewDecoder(buf)
@@ -291,10 +636,18 @@ func (fp *FileProcessor) calculateFileHash(path string, limit int64) (string, er
 		log.Printf("Hash calculation for %s took %v", path, duration)
 	}()
 
-	// 尝试从缓存获取
-	hash, err := fp.getHashFromCache(path, limit)
+	hasher := fp.hasherFor(limit)
+
+	// 尝试从缓存获取 - 先查本地 filecache（见 filecache.go），未命中再查 Redis
+	cached, err := fp.getHashFromCacheLayered(path, limit)
 	if err == nil {
-		return hash, nil
+		if algo, _ := splitHashAlgo(cached); algo == hasher.Name() {
+			if fp.HashCacheMode != HashCacheWrites {
+				fp.touchAtime(fp.generateHashFunc(path))
+			}
+			return cached, nil
+		}
+		log.Printf("Migrating stale-algorithm hash for %s from %q to %s", path, cached, hasher.Name())
 	} else if err != redis.Nil {
 		return "", fmt.Errorf("redis error: %w", err)
 	}
@@ -324,20 +677,24 @@ func (fp *FileProcessor) calculateFileHash(path string, limit int64) (string, er
 	defer f.Close()
 
 	// 计算哈希
-	h := sha512.New()
+	h := hasher.New()
 	buf := make([]byte, 32*1024)
 
 	if limit == FullFileReadCmd {
-		if _, err := io.CopyBuffer(h, f, buf); err != nil {
+		n, err := io.CopyBuffer(h, f, buf)
+		if err != nil {
 			return "", fmt.Errorf("error reading full file: %w", err)
 		}
+		fp.Metrics.AddBytesReadFull(n)
 	} else {
-		if _, err := io.CopyN(h, f, limit); err != nil && err != io.EOF {
+		n, err := io.CopyN(h, f, limit)
+		if err != nil && err != io.EOF {
 			return "", fmt.Errorf("error reading file: %w", err)
 		}
+		fp.Metrics.AddBytesReadPartial(n)
 	}
 
-	hash = fmt.Sprintf("%x", h.Sum(nil))
+	hash := hashWithAlgoPrefix(hasher.Name(), h.Sum(nil))
 
 	// 获取缓存键（重用 getHashFromCache 中的逻辑）
 	prefix := "hashedKeyToFileHash"
@@ -347,9 +704,19 @@ func (fp *FileProcessor) calculateFileHash(path string, limit int64) (string, er
 	hashedKey := fp.generateHashFunc(path)
 	cacheKey := fmt.Sprintf("%s:%s", prefix, hashedKey)
 
-	// 缓存结果
-	if err := fp.Rdb.Set(fp.Ctx, cacheKey, hash, 0).Err(); err != nil {
-		log.Printf("Warning: Failed to cache hash for %s: %v", path, err)
+	// 缓存结果, 除非 HashCacheMode 禁止了这一层级的持久化
+	persist := fp.shouldPersistPartialHash()
+	if limit == FullFileReadCmd {
+		persist = fp.shouldPersistFullHash()
+	}
+	if persist {
+		if err := fp.Rdb.Set(fp.Ctx, cacheKey, hash, 0).Err(); err != nil {
+			log.Printf("Warning: Failed to cache hash for %s: %v", path, err)
+		}
+		if err := fp.Caches.Get(fileCacheNameForLimit(limit)).Put(path, []byte(hash)); err != nil {
+			log.Printf("Warning: Failed to write filecache entry for %s: %v", path, err)
+		}
+		fp.touchAtime(hashedKey)
 	}
 
 	return hash, nil
@@ -371,6 +738,36 @@ func (fp *FileProcessor) waitForHash(path string, limit int64) (string, error) {
 	return "", fmt.Errorf("timeout waiting for hash calculation")
 }
 
+// fileCacheNameForLimit returns the named local cache (see filecache.go)
+// calculateFileHash's two tiers consult, mirroring the hashedKeyToFileHash/
+// hashedKeyToFullHash split their Redis keys already use.
+func fileCacheNameForLimit(limit int64) string {
+	if limit == FullFileReadCmd {
+		return "fullhash"
+	}
+	return "partialhash"
+}
+
+// getHashFromCacheLayered checks fp.Caches' named cache for path's hash
+// before falling back to Redis via getHashFromCache, so a laptop run that
+// already has a warm local cache never has to reach Redis for a hit. A miss
+// on both layers returns redis.Nil, same as a bare getHashFromCache miss.
+func (fp *FileProcessor) getHashFromCacheLayered(path string, limit int64) (string, error) {
+	cache := fp.Caches.Get(fileCacheNameForLimit(limit))
+	if !cache.Enabled() {
+		return fp.getHashFromCache(path, limit)
+	}
+
+	value, err := cache.GetOrCreate(path, func() ([]byte, error) {
+		hash, err := fp.getHashFromCache(path, limit)
+		return []byte(hash), err
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
 func (fp *FileProcessor) getHashFromCache(path string, limit int64) (string, error) {
 	prefix := "hashedKeyToFileHash"
 	if limit == FullFileReadCmd {
@@ -379,11 +776,21 @@ func (fp *FileProcessor) getHashFromCache(path string, limit int64) (string, err
 	hashedKey := fp.generateHashFunc(path)
 	cacheKey := fmt.Sprintf("%s:%s", prefix, hashedKey)
 
+	if fp.HashedKeyLRU != nil {
+		if obj, ok := fp.HashedKeyLRU.Get(cacheKey); ok {
+			return string(obj.(stringValue)), nil
+		}
+	}
+
 	hash, err := fp.Rdb.Get(fp.Ctx, cacheKey).Result()
 	if err != nil {
 		return "", err
 	}
 
+	if fp.HashedKeyLRU != nil {
+		fp.HashedKeyLRU.Put(cacheKey, stringValue(hash))
+	}
+
 	return hash, nil
 }
 
@@ -398,17 +805,17 @@ func processSymlink(path string) {
 }
 
 // 处理关键词
-func processKeyword(keyword string, keywordFiles []string, Rdb *redis.Client, Ctx context.Context, rootDir string, excludeRegexps []*regexp.Regexp) {
+func processKeyword(keyword string, keywordFiles []string, Rdb *redis.Client, Ctx context.Context, rootDir string, excludeRules *ExcludeRuleSet, fs afero.Fs) {
 	// 对 keywordFiles 进行排序
 	sort.Slice(keywordFiles, func(i, j int) bool {
 		relativePathI := cleanRelativePath(rootDir, keywordFiles[i])
 		relativePathJ := cleanRelativePath(rootDir, keywordFiles[j])
-		sizeI, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePathI, excludeRegexps)
+		sizeI, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePathI, excludeRules)
 		if err != nil {
 			log.Printf("Error getting file size for %s: %v", keywordFiles[i], err)
 			return false
 		}
-		sizeJ, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePathJ, excludeRegexps)
+		sizeJ, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePathJ, excludeRules)
 		if err != nil {
 			log.Printf("Error getting file size for %s: %v", keywordFiles[j], err)
 			return false
@@ -421,7 +828,7 @@ func processKeyword(keyword string, keywordFiles []string, Rdb *redis.Client, Ct
 	outputData.WriteString(keyword + "\n")
 	for _, filePath := range keywordFiles {
 		relativePath := cleanRelativePath(rootDir, filePath)
-		fileSize, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePath, excludeRegexps)
+		fileSize, err := getFileSizeFromRedis(Rdb, Ctx, rootDir, relativePath, excludeRules)
 		if err != nil {
 			log.Printf("Error getting file size for %s: %v", filePath, err)
 			continue
@@ -431,14 +838,10 @@ func processKeyword(keyword string, keywordFiles []string, Rdb *redis.Client, Ct
 
 	// 创建并写入文件
 	outputFilePath := filepath.Join(rootDir, keyword+".txt")
-	outputFile, err := os.Create(outputFilePath)
-	if err != nil {
-		log.Printf("Error creating output file %s: %v", outputFilePath, err)
-		return
-	}
-	defer outputFile.Close()
-
-	_, err = outputFile.WriteString(outputData.String())
+	err := atomicWriteFile(fs, outputFilePath, defaultKeepRotations, false, func(w io.Writer) error {
+		_, err := io.WriteString(w, outputData.String())
+		return err
+	})
 	if err != nil {
 		log.Printf("Error writing to output file %s: %v", outputFilePath, err)
 	}
@@ -479,5 +882,19 @@ type FileInfo struct {
 }
 
 func (fp *FileProcessor) getHashedKeyFromPath(path string) (string, error) {
-	return fp.Rdb.Get(fp.Ctx, "pathToHashedKey:"+filepath.Clean(path)).Result()
+	cacheKey := getPathToHashedKeyKey(filepath.Clean(path))
+	if fp.HashedKeyLRU != nil {
+		if obj, ok := fp.HashedKeyLRU.Get(cacheKey); ok {
+			return string(obj.(stringValue)), nil
+		}
+	}
+
+	hashedKey, err := fp.Rdb.Get(fp.Ctx, cacheKey).Result()
+	if err != nil {
+		return "", err
+	}
+	if fp.HashedKeyLRU != nil {
+		fp.HashedKeyLRU.Put(cacheKey, stringValue(hashedKey))
+	}
+	return hashedKey, nil
 }
@@ -23,7 +23,7 @@ func TestFileProcessorIntegration(t *testing.T) {
 	ctx := context.Background()
 	fs := afero.NewMemMapFs()
 
-	fp := CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	// Create test directory structure
@@ -30,7 +30,7 @@ func setupTestEnvironment(t *testing.T) (*miniredis.Miniredis, *redis.Client, co
 	ctx := context.Background()
 	fs := afero.NewMemMapFs()
 
-	fp := CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	// Clear all data in Redis before each test
@@ -360,7 +360,7 @@ func TestWriteDuplicateFilesToFileWithMockData(t *testing.T) {
 	require.NoError(t, err)
 	defer fs.RemoveAll(tempDir)
 
-	fp = CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp = CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	// 模拟重复文件数据
@@ -752,6 +752,94 @@ func TestCalculateFileHash(t *testing.T) {
 	}
 }
 
+// TestCalculateFileHashAlgorithmMatrix runs calculateFileHash's head-hash
+// tier across every Hasher implementation, checking that the stored digest
+// is namespaced with the algorithm that produced it. Migration of a cache
+// entry from a different algorithm is covered by
+// TestCalculateFileHashMigratesStaleAlgorithm.
+func TestCalculateFileHashAlgorithmMatrix(t *testing.T) {
+	algorithms := []struct {
+		name   string
+		hasher Hasher
+	}{
+		{"sha512", SHA512Hasher},
+		{"xxh64", XXHash64Hasher},
+		{"blake3", BLAKE3Hasher},
+		{"xxh3", XXH3Hasher},
+	}
+
+	for _, alg := range algorithms {
+		t.Run(alg.name, func(t *testing.T) {
+			_, _, _, fs, fp := setupTestEnvironment(t)
+			fp.HeadHasher = alg.hasher
+
+			testFilePath := "/testfile.txt"
+			require.NoError(t, afero.WriteFile(fs, testFilePath, []byte("This is a test file content"), 0644))
+
+			hash, err := fp.calculateFileHash(testFilePath, ReadLimit)
+			require.NoError(t, err)
+
+			algo, digest := splitHashAlgo(hash)
+			assert.Equal(t, alg.hasher.Name(), algo)
+			assert.NotEmpty(t, digest)
+		})
+	}
+}
+
+// TestCalculateFileHashMigratesStaleAlgorithm exercises the migration path:
+// a cached hash produced by one algorithm is replaced the next time
+// calculateFileHash runs under a different Hasher, rather than being
+// returned unchanged.
+func TestCalculateFileHashMigratesStaleAlgorithm(t *testing.T) {
+	_, _, _, fs, fp := setupTestEnvironment(t)
+
+	testFilePath := "/testfile.txt"
+	require.NoError(t, afero.WriteFile(fs, testFilePath, []byte("This is a test file content"), 0644))
+
+	fp.HeadHasher = SHA512Hasher
+	sha512Hash, err := fp.calculateFileHash(testFilePath, ReadLimit)
+	require.NoError(t, err)
+	algo, _ := splitHashAlgo(sha512Hash)
+	assert.Equal(t, "sha512", algo)
+
+	fp.HeadHasher = XXHash64Hasher
+	xxHash, err := fp.calculateFileHash(testFilePath, ReadLimit)
+	require.NoError(t, err)
+	algo, _ = splitHashAlgo(xxHash)
+	assert.Equal(t, "xxh64", algo)
+	assert.NotEqual(t, sha512Hash, xxHash)
+}
+
+// BenchmarkHasherThroughput compares each Hasher's throughput on a 100 MiB
+// buffer, the scenario WithHeadHasher/WithFullHasher are meant to let
+// callers trade off: SHA512Hasher is cryptographic but slow, XXHash64Hasher
+// trades that for speed on the head-hash tier, and BLAKE3Hasher aims to
+// offer both for the full-hash tier.
+func BenchmarkHasherThroughput(b *testing.B) {
+	const size = 100 * 1024 * 1024
+	data := make([]byte, size)
+
+	hashers := []struct {
+		name   string
+		hasher Hasher
+	}{
+		{"sha512", SHA512Hasher},
+		{"xxh64", XXHash64Hasher},
+		{"blake3", BLAKE3Hasher},
+	}
+
+	for _, hc := range hashers {
+		b.Run(hc.name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				h := hc.hasher.New()
+				h.Write(data)
+				h.Sum(nil)
+			}
+		})
+	}
+}
+
 func TestCleanUpOldRecords(t *testing.T) {
 	mr, err := miniredis.Run()
 	require.NoError(t, err)
@@ -812,7 +900,7 @@ func TestProcessFileBoundary(t *testing.T) {
 	ctx := context.Background()
 
 	fs := afero.NewMemMapFs()
-	fp := CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	// 确保所有必要的函数都被初始化
@@ -940,7 +1028,11 @@ func TestFileOperationsWithSpecialChars(t *testing.T) {
 				hash, err := fp.calculateFileHashFunc(filePath, -1)
 				assert.NoError(t, err)
 				assert.NotEmpty(t, hash)
-				assert.Regexp(t, "^[0-9a-f]+$", hash)
+				// calculateFileHash prefixes its digest with the algorithm name
+				// (see hashWithAlgoPrefix) so stale-algorithm cache entries can be
+				// detected; assert on the digest half only.
+				_, digest := splitHashAlgo(hash)
+				assert.Regexp(t, "^[0-9a-f]+$", digest)
 			})
 
 			t.Run("ProcessFileWithHash", func(t *testing.T) {
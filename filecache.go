@@ -0,0 +1,309 @@
+// filecache.go
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// FileCacheConfig declares one named cache's on-disk location and eviction
+// limits, modeled on Hugo's consolidated filecache config (the same model
+// PrunePolicy's TTL/MaxEntries/MaxTotalBytes policies borrow, see
+// pruner.go). MaxAge of -1 means entries never expire; 0 disables the cache
+// entirely (every GetOrCreate call falls straight through to create).
+type FileCacheConfig struct {
+	Dir     string
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+// ParseFileCacheConfig reads a minimal TOML-subset config declaring named
+// caches:
+//
+//	[caches.fileinfo]
+//	dir = ":cacheDir:/large_file_finder/fileinfo"
+//	maxAge = "720h"
+//	maxSize = 536870912
+//
+// Blank lines and lines starting with '#' are ignored. This isn't a general
+// TOML parser - no nesting beyond one "[caches.NAME]" level, no arrays, no
+// quoting rules beyond stripping a single pair of surrounding double quotes
+// - but it covers what a [caches.*] block needs and avoids vendoring a TOML
+// library for it.
+func ParseFileCacheConfig(r io.Reader) (map[string]FileCacheConfig, error) {
+	configs := make(map[string]FileCacheConfig)
+	var section string
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header %q", lineNo, line)
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			const prefix = "caches."
+			if !strings.HasPrefix(name, prefix) {
+				return nil, fmt.Errorf("line %d: unsupported section %q (only [caches.NAME] is)", lineNo, name)
+			}
+			section = strings.TrimPrefix(name, prefix)
+			if _, exists := configs[section]; !exists {
+				configs[section] = FileCacheConfig{}
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("line %d: key %q outside any [caches.NAME] section", lineNo, line)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNo, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		cfg := configs[section]
+		switch key {
+		case "dir":
+			cfg.Dir = value
+		case "maxAge":
+			age, err := parseFileCacheMaxAge(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			cfg.MaxAge = age
+		case "maxSize":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid maxSize %q: %w", lineNo, value, err)
+			}
+			cfg.MaxSize = size
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+		configs[section] = cfg
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading filecache config: %w", err)
+	}
+
+	return configs, nil
+}
+
+// parseFileCacheMaxAge parses "-1" (never expire) or a time.ParseDuration
+// string; an empty value defaults to 0 (disabled), matching FileCacheConfig's
+// zero value.
+func parseFileCacheMaxAge(value string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	if value == "-1" {
+		return -1, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxAge %q: %w", value, err)
+	}
+	return d, nil
+}
+
+// expandFileCacheDirPlaceholders resolves the ":cacheDir:" and ":tempDir:"
+// placeholders a config's dir may start with, the same shorthand Hugo's
+// filecache config uses, so a config doesn't have to hardcode a
+// user-specific absolute path.
+func expandFileCacheDirPlaceholders(dir string) (string, error) {
+	switch {
+	case strings.HasPrefix(dir, ":cacheDir:"):
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("error resolving :cacheDir:: %w", err)
+		}
+		return filepath.Join(base, strings.TrimPrefix(dir, ":cacheDir:")), nil
+	case strings.HasPrefix(dir, ":tempDir:"):
+		return filepath.Join(os.TempDir(), strings.TrimPrefix(dir, ":tempDir:")), nil
+	default:
+		return dir, nil
+	}
+}
+
+// FileCache is one named, disk-backed cache sitting in front of Redis: a
+// hit returns without the caller ever touching fp.Rdb. Entries are stored as
+// one file per key, named by the hex SHA-256 of the key, under Dir.
+type FileCache struct {
+	fs      afero.Fs
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+
+	mu sync.Mutex
+}
+
+// newFileCache builds a FileCache from cfg, expanding any :cacheDir:/
+// :tempDir: placeholder in cfg.Dir and creating the directory if needed.
+func newFileCache(fs afero.Fs, cfg FileCacheConfig) (*FileCache, error) {
+	dir, err := expandFileCacheDirPlaceholders(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating filecache dir %s: %w", dir, err)
+	}
+	return &FileCache{fs: fs, dir: dir, maxAge: cfg.MaxAge, maxSize: cfg.MaxSize}, nil
+}
+
+// Enabled reports whether this cache is configured to do anything; a
+// MaxAge of 0 (FileCacheConfig's zero value) disables it.
+func (c *FileCache) Enabled() bool {
+	return c != nil && c.maxAge != 0
+}
+
+func (c *FileCache) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// GetOrCreate returns the cached blob for key if present and not expired
+// per MaxAge, otherwise calls create, stores its result (unless create
+// errors), and returns that. An entry older than MaxAge is evicted the next
+// time it's looked up rather than on a schedule, so an idle cache costs
+// nothing between runs.
+func (c *FileCache) GetOrCreate(key string, create func() ([]byte, error)) ([]byte, error) {
+	if !c.Enabled() {
+		return create()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.entryPath(key)
+	if info, err := c.fs.Stat(path); err == nil {
+		if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+			if err := c.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("error evicting expired filecache entry %s: %w", path, err)
+			}
+		} else {
+			value, err := afero.ReadFile(c.fs, path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading filecache entry %s: %w", path, err)
+			}
+			return value, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error checking filecache entry %s: %w", path, err)
+	}
+
+	value, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := afero.WriteFile(c.fs, path, value, 0644); err != nil {
+		return nil, fmt.Errorf("error writing filecache entry %s: %w", path, err)
+	}
+	c.evictToMaxSizeLocked()
+
+	return value, nil
+}
+
+// Put unconditionally writes value under key, overwriting any existing
+// entry, then enforces MaxSize. It's a no-op on a disabled cache, for
+// callers that already computed a fresh value themselves (e.g. after a
+// GetOrCreate miss) and just need it persisted for next time.
+func (c *FileCache) Put(key string, value []byte) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := afero.WriteFile(c.fs, c.entryPath(key), value, 0644); err != nil {
+		return fmt.Errorf("error writing filecache entry for key: %w", err)
+	}
+	c.evictToMaxSizeLocked()
+	return nil
+}
+
+// evictToMaxSizeLocked removes the oldest-by-ModTime entries under c.dir
+// until its total size is at or under c.maxSize. Called with c.mu already
+// held. A non-positive MaxSize disables this policy.
+func (c *FileCache) evictToMaxSizeLocked() {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	entries, err := afero.ReadDir(c.fs, c.dir)
+	if err != nil {
+		return
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size()
+	}
+	if total <= c.maxSize {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if err := c.fs.Remove(filepath.Join(c.dir, e.Name())); err != nil {
+			continue
+		}
+		total -= e.Size()
+	}
+}
+
+// Caches is the set of named FileCaches a FileProcessor consults before
+// Redis, analogous to Hugo's Caches registry. Configure via NewCaches and
+// attach with WithFileCaches.
+type Caches struct {
+	named map[string]*FileCache
+}
+
+// NewCaches builds one FileCache per entry in configs (typically the result
+// of ParseFileCacheConfig).
+func NewCaches(fs afero.Fs, configs map[string]FileCacheConfig) (*Caches, error) {
+	named := make(map[string]*FileCache, len(configs))
+	for name, cfg := range configs {
+		cache, err := newFileCache(fs, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error building cache %q: %w", name, err)
+		}
+		named[name] = cache
+	}
+	return &Caches{named: named}, nil
+}
+
+// Get returns the named cache, or nil if it isn't configured; nil has a
+// valid, always-disabled Enabled/GetOrCreate so callers don't need a
+// separate "is this cache configured" check.
+func (c *Caches) Get(name string) *FileCache {
+	if c == nil {
+		return nil
+	}
+	return c.named[name]
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileCacheConfig(t *testing.T) {
+	input := `
+# comment
+[caches.fileinfo]
+dir = ":cacheDir:/lff/fileinfo"
+maxAge = "720h"
+maxSize = 1000
+
+[caches.fullhash]
+dir = ":tempDir:/lff/fullhash"
+maxAge = "-1"
+`
+	configs, err := ParseFileCacheConfig(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+
+	assert.Equal(t, FileCacheConfig{
+		Dir:     ":cacheDir:/lff/fileinfo",
+		MaxAge:  720 * time.Hour,
+		MaxSize: 1000,
+	}, configs["fileinfo"])
+
+	assert.Equal(t, FileCacheConfig{
+		Dir:    ":tempDir:/lff/fullhash",
+		MaxAge: -1,
+	}, configs["fullhash"])
+}
+
+func TestParseFileCacheConfig_RejectsKeyOutsideSection(t *testing.T) {
+	_, err := ParseFileCacheConfig(strings.NewReader("dir = \"/tmp\"\n"))
+	assert.Error(t, err)
+}
+
+func TestFileCache_GetOrCreate_MissThenHit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	cache, err := newFileCache(fs, FileCacheConfig{Dir: "/cache", MaxAge: time.Hour})
+	require.NoError(t, err)
+
+	calls := 0
+	create := func() ([]byte, error) {
+		calls++
+		return []byte("computed"), nil
+	}
+
+	value, err := cache.GetOrCreate("/root/a.bin", create)
+	require.NoError(t, err)
+	assert.Equal(t, "computed", string(value))
+	assert.Equal(t, 1, calls)
+
+	value, err = cache.GetOrCreate("/root/a.bin", create)
+	require.NoError(t, err)
+	assert.Equal(t, "computed", string(value))
+	assert.Equal(t, 1, calls, "second call should hit the cache, not invoke create again")
+}
+
+func TestFileCache_GetOrCreate_ExpiredEntryIsRecomputed(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	cache, err := newFileCache(fs, FileCacheConfig{Dir: "/cache", MaxAge: time.Hour})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put("/root/a.bin", []byte("stale")))
+	require.NoError(t, fs.Chtimes(cache.entryPath("/root/a.bin"), time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)))
+
+	calls := 0
+	value, err := cache.GetOrCreate("/root/a.bin", func() ([]byte, error) {
+		calls++
+		return []byte("fresh"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", string(value))
+	assert.Equal(t, 1, calls)
+}
+
+func TestFileCache_GetOrCreate_MaxAgeZeroDisablesCache(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	cache, err := newFileCache(fs, FileCacheConfig{Dir: "/cache"})
+	require.NoError(t, err)
+
+	calls := 0
+	create := func() ([]byte, error) {
+		calls++
+		return []byte("computed"), nil
+	}
+
+	_, err = cache.GetOrCreate("/root/a.bin", create)
+	require.NoError(t, err)
+	_, err = cache.GetOrCreate("/root/a.bin", create)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "a disabled cache should never short-circuit create")
+
+	entries, err := afero.ReadDir(fs, "/cache")
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a disabled cache should never write an entry")
+}
+
+func TestFileCache_Put_EvictsOldestOverMaxSize(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	cache, err := newFileCache(fs, FileCacheConfig{Dir: "/cache", MaxAge: -1, MaxSize: 15})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put("a", []byte("0123456789")))
+	require.NoError(t, fs.Chtimes(cache.entryPath("a"), time.Now().Add(-time.Minute), time.Now().Add(-time.Minute)))
+	require.NoError(t, cache.Put("b", []byte("0123456789")))
+
+	entries, err := afero.ReadDir(fs, "/cache")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the older entry should have been evicted to stay under MaxSize")
+
+	value, err := cache.GetOrCreate("b", func() ([]byte, error) {
+		t.Fatal("b should still be cached")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(value))
+}
+
+func TestCaches_GetUnknownNameIsDisabled(t *testing.T) {
+	caches, err := NewCaches(afero.NewMemMapFs(), map[string]FileCacheConfig{
+		"fileinfo": {Dir: "/cache/fileinfo", MaxAge: time.Hour},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, caches.Get("fileinfo").Enabled())
+	assert.False(t, caches.Get("nonexistent").Enabled())
+
+	var nilCaches *Caches
+	assert.False(t, nilCaches.Get("fileinfo").Enabled())
+}
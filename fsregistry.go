@@ -0,0 +1,44 @@
+// fsregistry.go
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/afero"
+)
+
+// NewFsFromURL parses a URL-style filesystem root and returns an afero.Fs
+// rooted there, so CreateFileProcessor/NewCloseFileFinder can be pointed at
+// something other than a local path via WithFilesystem/WithFs:
+//
+//   - "file:///abs/path" or a bare "/abs/path" -> the real OS filesystem,
+//     based at that path via afero.NewBasePathFs so callers can't escape it
+//     with a "../" path.
+//   - "mem://" -> a fresh afero.NewMemMapFs(), useful for tests and for
+//     composing a scan entirely in memory.
+//   - "s3://bucket/prefix", "gs://bucket/prefix", "sftp://user@host/path" are
+//     recognized but return an error: none of the S3/GCS/SFTP afero backends
+//     are vendored in this build, and silently falling back to the local
+//     filesystem would scan the wrong tree.
+func NewFsFromURL(rawURL string) (afero.Fs, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing filesystem URL %q: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		root := u.Path
+		if root == "" {
+			root = rawURL
+		}
+		return afero.NewBasePathFs(afero.NewOsFs(), root), nil
+	case "mem":
+		return afero.NewMemMapFs(), nil
+	case "s3", "gs", "sftp":
+		return nil, fmt.Errorf("filesystem scheme %q is recognized but has no backend vendored in this build", u.Scheme)
+	default:
+		return nil, fmt.Errorf("unsupported filesystem scheme %q in %q", u.Scheme, rawURL)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFsFromURLFile(t *testing.T) {
+	tempDir := t.TempDir()
+	fs, err := NewFsFromURL("file://" + tempDir)
+	require.NoError(t, err)
+
+	require.NoError(t, afero.WriteFile(fs, "a.txt", []byte("hi"), 0644))
+	content, err := afero.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(content))
+
+	onDisk, err := afero.ReadFile(afero.NewOsFs(), filepath.Join(tempDir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(onDisk))
+}
+
+func TestNewFsFromURLMem(t *testing.T) {
+	fs, err := NewFsFromURL("mem://")
+	require.NoError(t, err)
+
+	require.NoError(t, afero.WriteFile(fs, "a.txt", []byte("hi"), 0644))
+	content, err := afero.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(content))
+}
+
+func TestNewFsFromURLUnsupportedBackends(t *testing.T) {
+	for _, rawURL := range []string{"s3://bucket/prefix", "gs://bucket/prefix", "sftp://user@host/path"} {
+		_, err := NewFsFromURL(rawURL)
+		assert.Error(t, err)
+	}
+}
+
+func TestNewFsFromURLUnknownScheme(t *testing.T) {
+	_, err := NewFsFromURL("ftp://host/path")
+	assert.Error(t, err)
+}
+
+func TestFileProcessorWithFilesystemOption(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	fs, err := NewFsFromURL("mem://")
+	require.NoError(t, err)
+
+	fp := CreateFileProcessor(rdb, ctx, nil, WithFilesystem(fs))
+
+	require.NoError(t, afero.WriteFile(fs, "/root/file1.txt", []byte("content1"), 0644))
+	require.NoError(t, fp.ProcessFile("/root", "file1.txt", true))
+
+	info, err := fp.Rdb.Get(ctx, "fileInfo:"+generateHash(filepath.Join("/root", "file1.txt"))).Result()
+	require.NoError(t, err)
+	assert.NotEmpty(t, info)
+}
@@ -0,0 +1,207 @@
+// gitignore.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// MatchResult is the outcome of testing a path against a Pattern or Matcher:
+// whether the path is explicitly re-included, explicitly excluded, or
+// untouched by any rule.
+type MatchResult int
+
+const (
+	NoMatch MatchResult = iota
+	Include
+	Exclude
+)
+
+// Pattern is one compiled .gitignore-style rule scoped to domain - the path
+// segments (relative to rootDir) of the directory the ignore file defining
+// it lives in - mirroring go-git's plumbing/format/gitignore.Pattern. A
+// Pattern only ever matches paths under its domain, which is what lets a
+// deeper directory's ignore file scope its rules to its own subtree instead
+// of affecting the whole scan.
+type Pattern struct {
+	domain   []string
+	segments []string
+	anchored bool
+	negate   bool
+	dirOnly  bool
+}
+
+// ParsePattern compiles one .gitignore line (already trimmed of surrounding
+// whitespace, with blank lines and "#" comments filtered out by the caller)
+// into a Pattern scoped to domain. It supports the same grammar git does: a
+// leading "!" negates the rule, a trailing "/" restricts it to directories,
+// a leading (or any non-trailing) "/" anchors it to domain rather than
+// letting it match at any depth below domain, and "**" matches zero or more
+// path segments.
+func ParsePattern(line string, domain []string) (Pattern, error) {
+	negate := strings.HasPrefix(line, "!")
+	if negate {
+		line = line[1:]
+	}
+
+	dirOnly := strings.HasSuffix(line, "/") && line != "/"
+	if dirOnly {
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	anchored := strings.HasPrefix(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	segments := strings.Split(line, "/")
+	if len(segments) > 1 {
+		anchored = true
+	}
+
+	for _, seg := range segments {
+		if seg == "**" {
+			continue
+		}
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return Pattern{}, fmt.Errorf("invalid pattern segment %q: %w", seg, err)
+		}
+	}
+
+	return Pattern{domain: domain, segments: segments, anchored: anchored, negate: negate, dirOnly: dirOnly}, nil
+}
+
+// Match reports how p resolves path, a full root-relative path split into
+// segments, given whether path is a directory. It returns NoMatch for any
+// path outside p.domain.
+func (p Pattern) Match(path []string, isDir bool) MatchResult {
+	if len(path) <= len(p.domain) {
+		return NoMatch
+	}
+	for i, seg := range p.domain {
+		if path[i] != seg {
+			return NoMatch
+		}
+	}
+
+	rest := path[len(p.domain):]
+
+	matched := false
+	if p.anchored {
+		matched = matchSegments(p.segments, rest)
+	} else {
+		for start := 0; start <= len(rest)-1 && !matched; start++ {
+			matched = matchSegments(p.segments, rest[start:])
+		}
+	}
+	if !matched {
+		return NoMatch
+	}
+	if p.dirOnly && !isDir {
+		return NoMatch
+	}
+	if p.negate {
+		return Include
+	}
+	return Exclude
+}
+
+// matchSegments reports whether pattern matches path exactly, where each
+// non-"**" pattern segment is matched against the corresponding path segment
+// via filepath.Match (so "*", "?", and "[...]" work within one segment), and
+// "**" matches zero or more whole segments.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return true
+		}
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// Matcher reports how a root-relative path resolves against a set of
+// Patterns.
+type Matcher interface {
+	Match(path []string, isDir bool) MatchResult
+}
+
+// PatternMatcher is a Matcher over an ordered list of Patterns, evaluated in
+// reverse order and stopping at the first decisive Include or Exclude - so a
+// pattern declared later (e.g. in a deeper, more specific .gitignore) takes
+// priority over one declared earlier, and a negated pattern can re-include a
+// path an ancestor directory's rule excluded.
+type PatternMatcher struct {
+	patterns []Pattern
+}
+
+// NewMatcher returns a PatternMatcher over patterns.
+func NewMatcher(patterns []Pattern) *PatternMatcher {
+	return &PatternMatcher{patterns: patterns}
+}
+
+func (m *PatternMatcher) Match(path []string, isDir bool) MatchResult {
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if r := m.patterns[i].Match(path, isDir); r != NoMatch {
+			return r
+		}
+	}
+	return NoMatch
+}
+
+// Excluded reports whether path should be skipped per m: true only when
+// Match returns Exclude; Include and NoMatch both leave the path in.
+func (m *PatternMatcher) Excluded(path []string, isDir bool) bool {
+	return m.Match(path, isDir) == Exclude
+}
+
+// ReadPatterns reads name (e.g. ".gitignore") from dir and compiles each of
+// its non-blank, non-comment lines into a Pattern scoped to domain - dir's
+// path relative to rootDir, split into segments ("" domain for rootDir
+// itself). A missing ignore file is not an error: it returns a nil slice, so
+// the common case (a directory with no ignore file) costs one failed Open.
+func ReadPatterns(fs afero.Fs, dir string, name string, domain []string) ([]Pattern, error) {
+	f, err := fs.Open(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error opening %s: %w", name, err)
+	}
+	defer f.Close()
+
+	var patterns []Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := ParsePattern(line, domain)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %s: %w", line, filepath.Join(dir, name), err)
+		}
+		patterns = append(patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return patterns, nil
+}
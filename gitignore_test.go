@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPattern_UnanchoredMatchesAtAnyDepth(t *testing.T) {
+	p, err := ParsePattern("*.tmp", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Exclude, p.Match([]string{"a.tmp"}, false))
+	assert.Equal(t, Exclude, p.Match([]string{"sub", "deep", "b.tmp"}, false))
+	assert.Equal(t, NoMatch, p.Match([]string{"a.txt"}, false))
+}
+
+func TestPattern_AnchoredOnlyMatchesFromDomain(t *testing.T) {
+	p, err := ParsePattern("/build", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Exclude, p.Match([]string{"build"}, true))
+	assert.Equal(t, NoMatch, p.Match([]string{"sub", "build"}, true))
+}
+
+func TestPattern_ScopedToDomain(t *testing.T) {
+	p, err := ParsePattern("*.log", []string{"sub"})
+	require.NoError(t, err)
+
+	assert.Equal(t, Exclude, p.Match([]string{"sub", "a.log"}, false))
+	assert.Equal(t, NoMatch, p.Match([]string{"other", "a.log"}, false), "pattern is scoped to the sub/ domain")
+}
+
+func TestPattern_DirOnlyDoesNotMatchFiles(t *testing.T) {
+	p, err := ParsePattern("build/", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Exclude, p.Match([]string{"build"}, true))
+	assert.Equal(t, NoMatch, p.Match([]string{"build"}, false))
+}
+
+func TestPattern_DoubleStarMatchesMultipleSegments(t *testing.T) {
+	p, err := ParsePattern("a/**/b", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Exclude, p.Match([]string{"a", "b"}, false))
+	assert.Equal(t, Exclude, p.Match([]string{"a", "x", "y", "b"}, false))
+	assert.Equal(t, NoMatch, p.Match([]string{"a", "b", "c"}, false))
+}
+
+func TestPatternMatcher_LaterNegationReincludes(t *testing.T) {
+	excludeAll, err := ParsePattern("*.log", nil)
+	require.NoError(t, err)
+	reinclude, err := ParsePattern("!keep.log", []string{"sub"})
+	require.NoError(t, err)
+
+	m := NewMatcher([]Pattern{excludeAll, reinclude})
+
+	assert.True(t, m.Excluded([]string{"a.log"}, false))
+	assert.False(t, m.Excluded([]string{"sub", "keep.log"}, false), "a deeper .gitignore's negation re-includes a path an ancestor excluded")
+}
+
+func TestReadPatterns_MissingFileReturnsNilSlice(t *testing.T) {
+	mr, _, _, fs, _ := setupTestEnvironment(t)
+	defer mr.Close()
+
+	patterns, err := ReadPatterns(fs, "/root", ".gitignore", nil)
+	require.NoError(t, err)
+	assert.Nil(t, patterns)
+}
+
+func TestReadPatterns_ParsesLinesSkippingBlanksAndComments(t *testing.T) {
+	mr, _, _, fs, _ := setupTestEnvironment(t)
+	defer mr.Close()
+	require.NoError(t, fs.MkdirAll("/root", 0755))
+	f, err := fs.Create("/root/.gitignore")
+	require.NoError(t, err)
+	_, err = f.WriteString("# comment\n\n*.tmp\n!keep.tmp\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	patterns, err := ReadPatterns(fs, "/root", ".gitignore", nil)
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+	assert.False(t, patterns[0].negate)
+	assert.True(t, patterns[1].negate)
+}
@@ -0,0 +1,184 @@
+// hash_algorithms.go
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+)
+
+// Hasher produces the hash.Hash calculateFileHash reads a file through, and
+// names the algorithm so the resulting digest can be namespaced in Redis
+// (see hashWithAlgoPrefix/splitHashAlgo). FileProcessor selects a Hasher
+// separately for the head-hash tier (WithHeadHasher, cheap and run on every
+// file) and the full-hash tier (WithFullHasher, run only when head hashes
+// collide), so a fast non-cryptographic algorithm can be used where
+// collisions are cheap to resolve by escalating to the full hash.
+type Hasher interface {
+	// Name is the algorithm prefix stored alongside each digest, e.g.
+	// "sha512", "xxh64", "blake3".
+	Name() string
+	New() hash.Hash
+}
+
+type sha512Hasher struct{}
+
+func (sha512Hasher) Name() string   { return "sha512" }
+func (sha512Hasher) New() hash.Hash { return sha512.New() }
+
+type xxhash64Hasher struct{}
+
+func (xxhash64Hasher) Name() string   { return "xxh64" }
+func (xxhash64Hasher) New() hash.Hash { return xxhash.New() }
+
+type blake3Hasher struct{}
+
+func (blake3Hasher) Name() string   { return "blake3" }
+func (blake3Hasher) New() hash.Hash { return blake3.New() }
+
+type md5Hasher struct{}
+
+func (md5Hasher) Name() string   { return "md5" }
+func (md5Hasher) New() hash.Hash { return md5.New() }
+
+type sha1Hasher struct{}
+
+func (sha1Hasher) Name() string   { return "sha1" }
+func (sha1Hasher) New() hash.Hash { return sha1.New() }
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string   { return "sha256" }
+func (sha256Hasher) New() hash.Hash { return sha256.New() }
+
+type xxh3Hasher struct{}
+
+func (xxh3Hasher) Name() string   { return "xxh3" }
+func (xxh3Hasher) New() hash.Hash { return xxh3.New() }
+
+// SHA512Hasher, XXHash64Hasher, BLAKE3Hasher, MD5Hasher, SHA1Hasher, and
+// SHA256Hasher are the Hasher values WithHeadHasher/WithFullHasher/
+// WithHashAlgorithms are constructed with. SHA512Hasher is FileProcessor's
+// default for both tiers, matching calculateFileHash's behavior before
+// per-tier hashers existed.
+var (
+	SHA512Hasher   Hasher = sha512Hasher{}
+	XXHash64Hasher Hasher = xxhash64Hasher{}
+	BLAKE3Hasher   Hasher = blake3Hasher{}
+	MD5Hasher      Hasher = md5Hasher{}
+	SHA1Hasher     Hasher = sha1Hasher{}
+	SHA256Hasher   Hasher = sha256Hasher{}
+	XXH3Hasher     Hasher = xxh3Hasher{}
+)
+
+// hasherRegistry maps an algorithm name to its Hasher, the set MultiHasher
+// (see multi_hash.go) and WithHashAlgorithms select from. It's a package
+// var rather than a const map so RegisterHasher can extend it at init time
+// with an algorithm this package doesn't ship.
+var hasherRegistry = map[string]Hasher{
+	SHA512Hasher.Name():   SHA512Hasher,
+	XXHash64Hasher.Name(): XXHash64Hasher,
+	BLAKE3Hasher.Name():   BLAKE3Hasher,
+	MD5Hasher.Name():      MD5Hasher,
+	SHA1Hasher.Name():     SHA1Hasher,
+	SHA256Hasher.Name():   SHA256Hasher,
+	XXH3Hasher.Name():     XXH3Hasher,
+}
+
+// HashType names a supported hash algorithm by its hasherRegistry key,
+// letting a caller like the --hash-algo flag or processFileHash (utils.go)
+// and computeFileDigests (hashing_reader.go) select an algorithm without
+// depending on the Hasher/hash.Hash machinery directly.
+type HashType string
+
+// Supported HashType values; each has a matching entry in hasherRegistry.
+const (
+	HashMD5    HashType = "md5"
+	HashSHA1   HashType = "sha1"
+	HashSHA256 HashType = "sha256"
+	HashSHA512 HashType = "sha512"
+	HashBLAKE3 HashType = "blake3"
+	HashXXH3   HashType = "xxh3"
+)
+
+// DefaultHashType is what calculateFileHash used unconditionally before
+// HashType existed.
+const DefaultHashType = HashSHA512
+
+// Hasher resolves t against hasherRegistry.
+func (t HashType) Hasher() (Hasher, bool) {
+	return LookupHasher(string(t))
+}
+
+func (t HashType) String() string { return string(t) }
+
+// datasetHashAlgoKey records, for a given Redis database, which algorithm
+// produced the partial/full hashes already stored under
+// fileHashToPathSet/hashedKeyToFileHash/hashedKeyToFullHash, so CheckDatasetHashAlgo
+// can refuse to mix hashes computed with two different algorithms.
+const datasetHashAlgoKey = "dataset:hashAlgo"
+
+// CheckDatasetHashAlgo compares algo (the algorithm this run is configured to
+// use) against whatever algorithm previously produced the hashes already
+// stored under fp.Rdb, recorded at datasetHashAlgoKey. If they differ and
+// rehash is false, it returns an error rather than letting a run silently
+// compare hashes produced by two different algorithms. If they match, no
+// prior algorithm is recorded (a fresh database), or rehash is true, it
+// (re)records algo as the dataset's algorithm and returns nil.
+func (fp *FileProcessor) CheckDatasetHashAlgo(algo string, rehash bool) error {
+	stored, err := fp.Rdb.Get(fp.Ctx, datasetHashAlgoKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("error reading dataset hash algorithm: %w", err)
+	}
+
+	if err != redis.Nil && stored != algo && !rehash {
+		return fmt.Errorf("redis dataset was hashed with %q but this run is configured for %q; pass -rehash to recompute with %q", stored, algo, algo)
+	}
+
+	if err := fp.Rdb.Set(fp.Ctx, datasetHashAlgoKey, algo, 0).Err(); err != nil {
+		return fmt.Errorf("error recording dataset hash algorithm: %w", err)
+	}
+	return nil
+}
+
+// RegisterHasher adds h to the registry WithHashAlgorithms and LookupHasher
+// resolve names against, so a caller can plug in an algorithm this package
+// doesn't ship without forking it.
+func RegisterHasher(h Hasher) {
+	hasherRegistry[h.Name()] = h
+}
+
+// LookupHasher resolves name (e.g. "sha256") to its registered Hasher.
+func LookupHasher(name string) (Hasher, bool) {
+	h, ok := hasherRegistry[name]
+	return h, ok
+}
+
+// hashWithAlgoPrefix formats digest as "<algo>:<hex>" so a value stored in
+// hashedKeyToFileHash/hashedKeyToFullHash records which Hasher produced it.
+func hashWithAlgoPrefix(algo string, digest []byte) string {
+	return algo + ":" + hex.EncodeToString(digest)
+}
+
+// splitHashAlgo separates a stored hash's algorithm prefix from its digest.
+// A value with no "<algo>:" prefix predates per-tier hashers and was always
+// produced by SHA512Hasher, so it's reported as algo "sha512" rather than
+// treated as unparseable - that lets calculateFileHash tell a legacy SHA-512
+// entry apart from one that genuinely needs migrating to a different
+// algorithm.
+func splitHashAlgo(stored string) (algo, digest string) {
+	if i := strings.IndexByte(stored, ':'); i >= 0 {
+		return stored[:i], stored[i+1:]
+	}
+	return SHA512Hasher.Name(), stored
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashType_Hasher(t *testing.T) {
+	hasher, ok := HashSHA256.Hasher()
+	require.True(t, ok)
+	assert.Equal(t, "sha256", hasher.Name())
+
+	_, ok = HashType("not-a-real-algorithm").Hasher()
+	assert.False(t, ok)
+}
+
+func TestFileProcessor_CheckDatasetHashAlgo_FreshDatasetRecordsAlgo(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fp.CheckDatasetHashAlgo("blake3", false))
+
+	stored, err := fp.Rdb.Get(fp.Ctx, datasetHashAlgoKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "blake3", stored)
+}
+
+func TestFileProcessor_CheckDatasetHashAlgo_MismatchRequiresRehash(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fp.CheckDatasetHashAlgo("sha512", false))
+
+	err := fp.CheckDatasetHashAlgo("blake3", false)
+	require.Error(t, err)
+
+	require.NoError(t, fp.CheckDatasetHashAlgo("blake3", true))
+	stored, err := fp.Rdb.Get(fp.Ctx, datasetHashAlgoKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "blake3", stored)
+}
+
+func TestFileProcessor_CheckDatasetHashAlgo_SameAlgoNeedsNoRehash(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fp.CheckDatasetHashAlgo("sha512", false))
+	require.NoError(t, fp.CheckDatasetHashAlgo("sha512", false))
+}
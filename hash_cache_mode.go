@@ -0,0 +1,181 @@
+// hash_cache_mode.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// HashCacheMode controls which of a file's hashes FileProcessor persists to
+// Redis, modeled on the VFS cache-mode knobs (off/partial/full/writeback)
+// some filesystems expose for trading memory against cache-hit rate.
+type HashCacheMode int
+
+const (
+	// HashCacheFull persists both the partial (head) hash and the full-file
+	// hash, matching FileProcessor's behavior before HashCacheMode existed.
+	// It's the default - a zero-value FileProcessor sees no behavior change.
+	HashCacheFull HashCacheMode = iota
+	// HashCacheOff persists neither hash; every calculateFileHash call
+	// recomputes from the file, trading Redis memory for CPU.
+	HashCacheOff
+	// HashCachePartial persists only the partial (head) hash used to bucket
+	// candidate duplicates, dropping the more expensive full-file hash once
+	// it's served its purpose in a given run.
+	HashCachePartial
+	// HashCacheWrites persists both hashes like HashCacheFull, but only
+	// refreshes atime:<hashedKey> on writes (ProcessFile) rather than on
+	// every read, so CleanUpByMode's TTL reflects how recently a file was
+	// (re)scanned rather than how recently it was looked up.
+	HashCacheWrites
+)
+
+// shouldPersistPartialHash reports whether calculateFileHash's ReadLimit
+// tier should cache its result, per fp.HashCacheMode.
+func (fp *FileProcessor) shouldPersistPartialHash() bool {
+	return fp.HashCacheMode != HashCacheOff
+}
+
+// shouldPersistFullHash reports whether calculateFileHash's FullFileReadCmd
+// tier should cache its result, per fp.HashCacheMode.
+func (fp *FileProcessor) shouldPersistFullHash() bool {
+	return fp.HashCacheMode != HashCacheOff && fp.HashCacheMode != HashCachePartial
+}
+
+// WithHashCacheMode overrides which hashes FileProcessor persists to Redis;
+// the default, an unset mode, is HashCacheFull.
+func WithHashCacheMode(mode HashCacheMode) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.HashCacheMode = mode }
+}
+
+const keyPrefixAtime = "atime:"
+
+func getAtimeKey(hashedKey string) string {
+	return keyPrefixAtime + hashedKey
+}
+
+// touchAtime records that hashedKey was just accessed, for CleanUpByMode's
+// partial-mode TTL to judge idle full-hash entries by.
+func (fp *FileProcessor) touchAtime(hashedKey string) {
+	if err := fp.Rdb.Set(fp.Ctx, getAtimeKey(hashedKey), time.Now().Unix(), 0).Err(); err != nil {
+		log.Printf("Warning: failed to update atime for %s: %v", hashedKey, err)
+	}
+}
+
+// CleanUpByModeStats reports how many full-hash entries CleanUpByMode
+// dropped and how many LRU eviction reclaimed against a memory budget.
+type CleanUpByModeStats struct {
+	FullHashesDropped int
+	LRUEvicted        int
+}
+
+// CleanUpByMode generalizes CleanUpOldRecords: in HashCachePartial mode, it
+// additionally drops hashedKeyToFullHash:<hashedKey> for any entry whose
+// atime:<hashedKey> is older than maxIdle (<=0 disables this pass), keeping
+// the cheaper partial hash around for bucketing while reclaiming the larger
+// full-hash cache. Other modes leave full hashes alone here; HashCacheOff
+// means there's nothing to drop since nothing was cached in the first place.
+func (fp *FileProcessor) CleanUpByMode(ctx context.Context, maxIdle time.Duration) (CleanUpByModeStats, error) {
+	var stats CleanUpByModeStats
+
+	if err := CleanUpOldRecords(fp.Rdb, ctx); err != nil {
+		return stats, err
+	}
+
+	if fp.HashCacheMode != HashCachePartial || maxIdle <= 0 {
+		return stats, nil
+	}
+
+	cutoff := time.Now().Add(-maxIdle)
+	iter := fp.Rdb.Scan(ctx, 0, keyPrefixFullHashCache+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		hashedKey := key[len(keyPrefixFullHashCache):]
+
+		idle := cutoff.Add(-time.Second) // default to "idle" if atime was never recorded
+		if raw, err := fp.Rdb.Get(ctx, getAtimeKey(hashedKey)).Int64(); err == nil {
+			idle = time.Unix(raw, 0)
+		}
+
+		if idle.Before(cutoff) {
+			pipe := fp.Rdb.Pipeline()
+			pipe.Del(ctx, key)
+			pipe.Del(ctx, getAtimeKey(hashedKey))
+			if _, err := pipe.Exec(ctx); err != nil {
+				log.Printf("Error dropping idle full hash %s: %v", hashedKey, err)
+				continue
+			}
+			stats.FullHashesDropped++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return stats, fmt.Errorf("error scanning full hash cache: %w", err)
+	}
+
+	return stats, nil
+}
+
+// lruCandidate is one "fileInfo:*" entry considered for EvictLRUByMemoryBudget,
+// along with the Redis memory its keys were sampled to use.
+type lruCandidate struct {
+	entry     pruneEntry
+	atime     time.Time
+	bytesUsed int64
+}
+
+// EvictLRUByMemoryBudget estimates the Redis footprint of every cached
+// entry's fileInfo key by its serialized (gob-encoded) length and, if the
+// total exceeds budgetBytes, evicts least-recently-accessed entries (oldest
+// atime:<hashedKey> first) until the estimate falls back under budget. This
+// uses STRLEN rather than MEMORY USAGE, which real Redis supports but
+// miniredis (this package's test backend) does not. Like Prune's policies,
+// it reuses evictPruneEntry so an evicted entry's
+// fileHashToPathSet/duplicateFiles membership is cleaned up alongside its
+// fileInfo/hash records.
+func (fp *FileProcessor) EvictLRUByMemoryBudget(ctx context.Context, budgetBytes int64) (int, error) {
+	entries, err := fp.collectPruneEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	candidates := make([]lruCandidate, 0, len(entries))
+	var totalBytes int64
+	for _, e := range entries {
+		used, err := fp.Rdb.StrLen(ctx, getFileInfoKey(e.hashedKey)).Result()
+		if err != nil {
+			continue
+		}
+
+		atime := e.lastSeen
+		if raw, err := fp.Rdb.Get(ctx, getAtimeKey(e.hashedKey)).Int64(); err == nil {
+			atime = time.Unix(raw, 0)
+		}
+
+		candidates = append(candidates, lruCandidate{entry: e, atime: atime, bytesUsed: used})
+		totalBytes += used
+	}
+
+	if totalBytes <= budgetBytes {
+		return 0, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].atime.Before(candidates[j].atime) })
+
+	evicted := 0
+	for _, c := range candidates {
+		if totalBytes <= budgetBytes {
+			break
+		}
+		if err := fp.evictPruneEntry(ctx, c.entry); err != nil {
+			log.Printf("Error evicting %s during LRU memory-budget prune: %v", c.entry.path, err)
+			continue
+		}
+		totalBytes -= c.bytesUsed
+		evicted++
+	}
+
+	return evicted, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_CalculateFileHash_HashCacheOff(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	fp.HashCacheMode = HashCacheOff
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("content"), 0644))
+
+	_, err := fp.calculateFileHash("/a.txt", ReadLimit)
+	require.NoError(t, err)
+	_, err = fp.calculateFileHash("/a.txt", FullFileReadCmd)
+	require.NoError(t, err)
+
+	hashedKey := generateHash("/a.txt")
+	_, err = rdb.Get(ctx, getHashCacheKey(hashedKey)).Result()
+	assert.Equal(t, redis.Nil, err)
+	_, err = rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestFileProcessor_CalculateFileHash_HashCachePartial(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	fp.HashCacheMode = HashCachePartial
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("content"), 0644))
+
+	_, err := fp.calculateFileHash("/a.txt", ReadLimit)
+	require.NoError(t, err)
+	_, err = fp.calculateFileHash("/a.txt", FullFileReadCmd)
+	require.NoError(t, err)
+
+	hashedKey := generateHash("/a.txt")
+	_, err = rdb.Get(ctx, getHashCacheKey(hashedKey)).Result()
+	assert.NoError(t, err)
+	_, err = rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestFileProcessor_CalculateFileHash_HashCacheFull(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("content"), 0644))
+
+	_, err := fp.calculateFileHash("/a.txt", ReadLimit)
+	require.NoError(t, err)
+	_, err = fp.calculateFileHash("/a.txt", FullFileReadCmd)
+	require.NoError(t, err)
+
+	hashedKey := generateHash("/a.txt")
+	_, err = rdb.Get(ctx, getHashCacheKey(hashedKey)).Result()
+	assert.NoError(t, err)
+	_, err = rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+	assert.NoError(t, err)
+}
+
+func TestFileProcessor_CleanUpByMode_DropsIdleFullHashesInPartialMode(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	_ = fs
+
+	fp.HashCacheMode = HashCachePartial
+
+	hashedKey := "idle-entry"
+	require.NoError(t, rdb.Set(ctx, getFullHashCacheKey(hashedKey), "somehash", 0).Err())
+	require.NoError(t, rdb.Set(ctx, getAtimeKey(hashedKey), time.Now().Add(-48*time.Hour).Unix(), 0).Err())
+
+	stats, err := fp.CleanUpByMode(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.FullHashesDropped)
+
+	_, err = rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestFileProcessor_EvictLRUByMemoryBudget(t *testing.T) {
+	mr, _, ctx, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/old.txt", 100, now, now.Add(-time.Hour), "oldHash", "")
+	seedPruneEntry(t, fp, "/new.txt", 100, now, now, "newHash", "")
+
+	evicted, err := fp.EvictLRUByMemoryBudget(ctx, 1)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, evicted, 1)
+
+	assertEntryGone(t, fp, "/old.txt", "oldHash", "")
+}
@@ -0,0 +1,197 @@
+// hashing_reader.go
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+)
+
+// boundedHasher is one named hash.Hash registered with a HashingReader,
+// together with how many of the stream's bytes it should accumulate before
+// it stops writing. limit <= 0 means unbounded - keep writing for the whole
+// stream.
+type boundedHasher struct {
+	hash.Hash
+	limit int64
+	done  int64
+}
+
+// HashingReader wraps an io.Reader and tees every byte that passes through
+// Read into one or more named hash.Hash instances, so a file can be hashed
+// by several algorithms - and at more than one length boundary, such as a
+// bounded "head" hash alongside an unbounded full hash - in a single pass
+// instead of being reopened and re-read once per algorithm. Inspired by
+// restic's hashing.Reader.
+type HashingReader struct {
+	r     io.Reader
+	named map[string]*boundedHasher
+	total int64
+}
+
+// NewHashingReader wraps r; use WithHasher to register the hashes to
+// compute before reading from it.
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r, named: make(map[string]*boundedHasher)}
+}
+
+// WithHasher registers h under name, accumulating over the first limit
+// bytes of the stream (limit <= 0 means the whole stream). Returns hr so
+// calls can be chained.
+func (hr *HashingReader) WithHasher(name string, h hash.Hash, limit int64) *HashingReader {
+	hr.named[name] = &boundedHasher{Hash: h, limit: limit}
+	return hr
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	if n > 0 {
+		for _, bh := range hr.named {
+			if bh.limit > 0 && bh.done >= bh.limit {
+				continue
+			}
+			chunk := p[:n]
+			if bh.limit > 0 && bh.done+int64(n) > bh.limit {
+				chunk = p[:bh.limit-bh.done]
+			}
+			bh.Write(chunk)
+			bh.done += int64(len(chunk))
+		}
+		hr.total += int64(n)
+	}
+	return n, err
+}
+
+// PartialSum returns the current digest of every hasher registered with
+// limit n, e.g. PartialSum(ReadLimit) for a bounded "head" hash once at
+// least n bytes have passed through Read.
+func (hr *HashingReader) PartialSum(n int64) map[string][]byte {
+	out := make(map[string][]byte)
+	for name, bh := range hr.named {
+		if bh.limit == n {
+			out[name] = bh.Sum(nil)
+		}
+	}
+	return out
+}
+
+// Sum returns the final digest of every unbounded hasher (limit <= 0),
+// valid once the stream has been fully read.
+func (hr *HashingReader) Sum() map[string][]byte {
+	out := make(map[string][]byte)
+	for name, bh := range hr.named {
+		if bh.limit <= 0 {
+			out[name] = bh.Sum(nil)
+		}
+	}
+	return out
+}
+
+// Checksums holds one file's digest under several general-purpose
+// algorithms, persisted as a Redis hash (see saveChecksums) so downstream
+// tooling - snapraid, rsync, artifact repos - can read whichever digest it
+// needs without decoding calculateFileHash's gob-free, algorithm-prefixed
+// string format. A blank field means that algorithm wasn't configured via
+// -checksum-algos for this run.
+type Checksums struct {
+	MD5    string
+	SHA1   string
+	SHA256 string
+	XXH3   string
+}
+
+const keyPrefixChecksums = "checksums:"
+
+func getChecksumsKey(hashedKey string) string {
+	return keyPrefixChecksums + hashedKey
+}
+
+// saveChecksums persists checksums as a Redis hash via HSET, one field per
+// non-blank algorithm, under checksums:<hashedKey>. A Checksums with every
+// field blank (no -checksum-algos configured) is a no-op.
+func saveChecksums(rdb *redis.Client, ctx context.Context, hashedKey string, checksums Checksums) error {
+	fields := make(map[string]interface{})
+	if checksums.MD5 != "" {
+		fields["md5"] = checksums.MD5
+	}
+	if checksums.SHA1 != "" {
+		fields["sha1"] = checksums.SHA1
+	}
+	if checksums.SHA256 != "" {
+		fields["sha256"] = checksums.SHA256
+	}
+	if checksums.XXH3 != "" {
+		fields["xxh3"] = checksums.XXH3
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := rdb.HSet(ctx, getChecksumsKey(hashedKey), fields).Err(); err != nil {
+		return fmt.Errorf("error saving checksums for %s: %w", hashedKey, err)
+	}
+	return nil
+}
+
+// computeFileDigests opens path exactly once and, via a HashingReader,
+// computes hashType's head hash (the first ReadLimit bytes), hashType's
+// full hash, and a Checksums record covering whichever of
+// checksumAlgorithms ("md5", "sha1", "sha256", "xxh3") LookupHasher
+// recognizes - replacing the two separate opens/reads getFileHash and
+// getFullFileHash used to require.
+func computeFileDigests(fs afero.Fs, path string, hashType HashType, checksumAlgorithms []string) (headHash, fullHash string, checksums Checksums, err error) {
+	hasher, ok := hashType.Hasher()
+	if !ok {
+		hasher, _ = DefaultHashType.Hasher()
+	}
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", "", Checksums{}, fmt.Errorf("error opening file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	hr := NewHashingReader(f)
+	hr.WithHasher("head", hasher.New(), ReadLimit)
+	hr.WithHasher("full", hasher.New(), 0)
+
+	for _, algo := range checksumAlgorithms {
+		if h, ok := LookupHasher(algo); ok {
+			hr.WithHasher("checksum:"+algo, h.New(), 0)
+		}
+	}
+
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		return "", "", Checksums{}, fmt.Errorf("error reading file %q: %w", path, err)
+	}
+
+	partial := hr.PartialSum(ReadLimit)
+	full := hr.Sum()
+
+	headHash = hashWithAlgoPrefix(hasher.Name(), partial["head"])
+	fullHash = hashWithAlgoPrefix(hasher.Name(), full["full"])
+
+	for _, algo := range checksumAlgorithms {
+		digest, ok := full["checksum:"+algo]
+		if !ok {
+			continue
+		}
+		hexDigest := hex.EncodeToString(digest)
+		switch algo {
+		case "md5":
+			checksums.MD5 = hexDigest
+		case "sha1":
+			checksums.SHA1 = hexDigest
+		case "sha256":
+			checksums.SHA256 = hexDigest
+		case "xxh3":
+			checksums.XXH3 = hexDigest
+		}
+	}
+
+	return headHash, fullHash, checksums, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashingReader_PartialAndFullSumsMatchSeparateHashers(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefgh"), 1024) // 8KB
+
+	hr := NewHashingReader(bytes.NewReader(content))
+	hr.WithHasher("head", md5.New(), 100)
+	hr.WithHasher("full", md5.New(), 0)
+
+	n, err := bytesCopy(hr)
+	require.NoError(t, err)
+	assert.Equal(t, len(content), n)
+
+	wantHead := md5.Sum(content[:100])
+	wantFull := md5.Sum(content)
+
+	partial := hr.PartialSum(100)
+	assert.Equal(t, wantHead[:], partial["head"])
+
+	full := hr.Sum()
+	assert.Equal(t, wantFull[:], full["full"])
+}
+
+func bytesCopy(hr *HashingReader) (int, error) {
+	n, err := io.Copy(io.Discard, hr)
+	return int(n), err
+}
+
+func TestComputeFileDigests_HeadAndFullDifferForLargeFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("x"), ReadLimit*2)
+	require.NoError(t, afero.WriteFile(fs, "/big.bin", content, 0644))
+
+	head, full, checksums, err := computeFileDigests(fs, "/big.bin", HashSHA512, []string{"md5", "sha256"})
+	require.NoError(t, err)
+	assert.NotEqual(t, head, full)
+
+	wantMD5 := md5.Sum(content)
+	wantSHA256 := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(wantMD5[:]), checksums.MD5)
+	assert.Equal(t, hex.EncodeToString(wantSHA256[:]), checksums.SHA256)
+}
+
+func TestComputeFileDigests_UnknownChecksumAlgoIsSkipped(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/small.bin", []byte("hello"), 0644))
+
+	_, _, checksums, err := computeFileDigests(fs, "/small.bin", HashSHA512, []string{"not-a-real-algo"})
+	require.NoError(t, err)
+	assert.Equal(t, Checksums{}, checksums)
+}
+
+func TestSaveChecksums_WritesRedisHashAndSkipsWhenEmpty(t *testing.T) {
+	mr, rdb, ctx, _, _ := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, saveChecksums(rdb, ctx, "somekey", Checksums{MD5: "deadbeef", SHA256: "cafebabe"}))
+
+	got, err := rdb.HGetAll(ctx, getChecksumsKey("somekey")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"md5": "deadbeef", "sha256": "cafebabe"}, got)
+
+	require.NoError(t, saveChecksums(rdb, ctx, "emptykey", Checksums{}))
+	exists, err := rdb.Exists(ctx, getChecksumsKey("emptykey")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), exists)
+}
@@ -0,0 +1,105 @@
+// ignore_walk.go
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// walkFilesWithIgnore walks rootDir like walkFiles, but in addition to
+// fp.ShouldExclude's central exclude-pattern-file rules, it maintains a
+// stack of Patterns picked up from an ignoreFileName (e.g. ".gitignore")
+// file in each directory it descends into, scoped to that directory's
+// subtree. This lets a directory - typically the root of a separately
+// maintained disk or dataset - carry its own exclude rules instead of every
+// path needing an entry in the one central exclude_patterns.txt.
+func walkFilesWithIgnore(ctx context.Context, rootDir string, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor, checkpoint *Checkpoint, ignoreFileName string) error {
+	return walkDirWithIgnore(ctx, rootDir, rootDir, nil, minSizeBytes, fileChan, fp, checkpoint, ignoreFileName)
+}
+
+func walkDirWithIgnore(ctx context.Context, rootDir, dir string, patterns []Pattern, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor, checkpoint *Checkpoint, ignoreFileName string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	relDir, err := filepath.Rel(rootDir, dir)
+	if err != nil {
+		log.Printf("Error getting relative path for %q: %v", dir, err)
+		return nil
+	}
+	var domain []string
+	if relDir != "." {
+		domain = strings.Split(relDir, string(filepath.Separator))
+	}
+
+	dirPatterns, err := ReadPatterns(fp.fs, dir, ignoreFileName, domain)
+	if err != nil {
+		log.Printf("Error reading %s in %q: %v", ignoreFileName, dir, err)
+	}
+	patterns = append(patterns, dirPatterns...)
+	matcher := NewMatcher(patterns)
+
+	entries, err := afero.ReadDir(fp.fs, dir)
+	if err != nil {
+		log.Printf("Error reading directory %q: %v", dir, err)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		childPath := filepath.Join(dir, entry.Name())
+		childRel, err := filepath.Rel(rootDir, childPath)
+		if err != nil {
+			log.Printf("Error getting relative path for %q: %v", childPath, err)
+			continue
+		}
+		childSegments := strings.Split(childRel, string(filepath.Separator))
+
+		if fp.ShouldExclude(childPath) || matcher.Excluded(childSegments, entry.IsDir()) {
+			if !entry.IsDir() {
+				metrics.IncFilesSkippedExcluded()
+			}
+			continue
+		}
+
+		if entry.IsDir() {
+			if err := walkDirWithIgnore(ctx, rootDir, childPath, patterns, minSizeBytes, fileChan, fp, checkpoint, ignoreFileName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if entry.Mode()&os.ModeSymlink != 0 {
+			log.Printf("Skipping symlink: %q", childPath)
+			continue
+		}
+
+		if entry.Size() < minSizeBytes {
+			metrics.IncFilesSkippedMinSize()
+			continue
+		}
+
+		skip, err := fp.ShouldSkipForResume(checkpoint, childRel)
+		if err != nil {
+			log.Printf("Error checking resume state for %q: %v", childRel, err)
+		} else if skip {
+			continue
+		}
+
+		select {
+		case fileChan <- childRel:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
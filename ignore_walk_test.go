@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkFilesWithIgnore_AppliesPerDirectoryGitignore(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/root/.gitignore", []byte("*.tmp\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/keep.bin", make([]byte, 1024), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/skip.tmp", make([]byte, 1024), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/sub/.gitignore", []byte("!skip.tmp\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/sub/skip.tmp", make([]byte, 1024), 0644))
+
+	fileChan := make(chan string, 10)
+	require.NoError(t, walkFilesWithIgnore(context.Background(), "/root", 100, fileChan, fp, nil, ".gitignore"))
+	close(fileChan)
+
+	var got []string
+	for p := range fileChan {
+		got = append(got, p)
+	}
+	assert.ElementsMatch(t, []string{"keep.bin", "sub/skip.tmp"}, got, "skip.tmp at root is excluded by the root .gitignore, but sub/skip.tmp is re-included by sub's own .gitignore negation; the .gitignore files themselves are below minSizeBytes")
+}
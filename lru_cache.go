@@ -0,0 +1,141 @@
+// lru_cache.go
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultHashedKeyLRUBytes and defaultFileInfoLRUBytes are the byte budgets
+// FileProcessor's in-process LRU caches use when not overridden: a few tens
+// of MB total, split unevenly because a hashedKey/hash string entry is a few
+// dozen bytes while a gob-encoded FileInfo entry runs to a few hundred -
+// the same few-tens-of-MB figure buys far more of the former.
+const (
+	defaultHashedKeyLRUBytes = 8 * 1024 * 1024
+	defaultFileInfoLRUBytes  = 24 * 1024 * 1024
+)
+
+// LRUObject is anything a byteBoundedLRU can size for its byte budget,
+// modeled on go-git's plumbing/cache.Object.
+type LRUObject interface {
+	Size() int64
+}
+
+// LRUCache is the Put/Get/Remove surface FileProcessor's in-process caches
+// need in front of Redis, modeled on go-git's plumbing/cache.Cache.
+type LRUCache interface {
+	Put(key string, obj LRUObject)
+	Get(key string) (LRUObject, bool)
+	Remove(key string)
+}
+
+// stringValue wraps a short string - a hashedKey, a partial/full hash - as
+// an LRUObject, sized by its byte length. It's the value type
+// NewHashedKeyLRU's cache stores.
+type stringValue string
+
+func (s stringValue) Size() int64 { return int64(len(s)) }
+
+// bufferValue wraps a larger []byte blob, typically a gob-encoded FileInfo,
+// as an LRUObject. It's the value type NewFileInfoLRU's cache stores.
+type bufferValue []byte
+
+func (b bufferValue) Size() int64 { return int64(len(b)) }
+
+// byteBoundedLRU is an LRUCache bounded by the total Size() of its entries
+// rather than by entry count, the same model go-git's ObjectLRU/BufferLRU
+// use: a handful of multi-hundred-byte FileInfo blobs and a flood of
+// few-byte hash strings shouldn't share one entry-count budget.
+type byteBoundedLRU struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	usedBytes int64
+	ll        *list.List
+	items     map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value LRUObject
+}
+
+// NewHashedKeyLRU returns a byte-bounded LRUCache sized for short strings
+// (path->hashedKey, hashedKey->hash); maxBytes<=0 uses
+// defaultHashedKeyLRUBytes.
+func NewHashedKeyLRU(maxBytes int64) LRUCache {
+	if maxBytes <= 0 {
+		maxBytes = defaultHashedKeyLRUBytes
+	}
+	return newByteBoundedLRU(maxBytes)
+}
+
+// NewFileInfoLRU returns a byte-bounded LRUCache sized for larger,
+// gob-encoded FileInfo blobs; maxBytes<=0 uses defaultFileInfoLRUBytes.
+func NewFileInfoLRU(maxBytes int64) LRUCache {
+	if maxBytes <= 0 {
+		maxBytes = defaultFileInfoLRUBytes
+	}
+	return newByteBoundedLRU(maxBytes)
+}
+
+func newByteBoundedLRU(maxBytes int64) *byteBoundedLRU {
+	return &byteBoundedLRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Put inserts or updates key, evicting least-recently-used entries until
+// the cache's total Size() is back at or under maxBytes.
+func (c *byteBoundedLRU) Put(key string, obj LRUObject) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.usedBytes -= el.Value.(*lruEntry).value.Size()
+		el.Value.(*lruEntry).value = obj
+		c.usedBytes += obj.Size()
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&lruEntry{key: key, value: obj})
+		c.items[key] = el
+		c.usedBytes += obj.Size()
+	}
+
+	for c.usedBytes > c.maxBytes && c.ll.Len() > 0 {
+		c.removeElementLocked(c.ll.Back())
+	}
+}
+
+// Get returns key's cached value and marks it most-recently-used.
+func (c *byteBoundedLRU) Get(key string) (LRUObject, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// Remove evicts key, if present. A caller invalidating a record that was
+// never cached is a no-op, not an error.
+func (c *byteBoundedLRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElementLocked(el)
+	}
+}
+
+func (c *byteBoundedLRU) removeElementLocked(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.usedBytes -= entry.value.Size()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteBoundedLRU_GetPutHit(t *testing.T) {
+	c := newByteBoundedLRU(1024)
+
+	c.Put("a", stringValue("1"))
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, stringValue("1"), v)
+
+	_, ok = c.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestByteBoundedLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newByteBoundedLRU(3)
+
+	c.Put("a", stringValue("1")) // size 1, used 1/3
+	c.Put("b", stringValue("1")) // size 1, used 2/3
+	c.Put("c", stringValue("1")) // size 1, used 3/3
+
+	// Touch "a" so it's most-recently-used, leaving "b" as the oldest.
+	_, _ = c.Get("a")
+
+	c.Put("d", stringValue("1")) // pushes usedBytes to 4/3, evicts "b"
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "b should have been evicted as least-recently-used")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	_, ok = c.Get("d")
+	assert.True(t, ok)
+}
+
+func TestByteBoundedLRU_Remove(t *testing.T) {
+	c := newByteBoundedLRU(1024)
+	c.Put("a", stringValue("1"))
+	c.Remove("a")
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	// Removing an absent key is a no-op, not a panic.
+	c.Remove("never-inserted")
+}
+
+func TestByteBoundedLRU_PutOverwritesSizeAccounting(t *testing.T) {
+	c := newByteBoundedLRU(10)
+	c.Put("a", stringValue("12345"))
+	c.Put("a", stringValue("1"))
+	c.Put("b", bufferValue(make([]byte, 8)))
+
+	_, ok := c.Get("a")
+	assert.True(t, ok, "overwriting a's value should have freed most of its original budget")
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+}
+
+func TestFileProcessor_GetHashedKeyFromPath_UsesLRUOnHit(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules, WithLRUCaches(NewHashedKeyLRU(0), NewFileInfoLRU(0)))
+
+	require.NoError(t, rdb.Set(ctx, "pathToHashedKey:/a.bin", "deadbeef", 0).Err())
+
+	hashedKey, err := fp.getHashedKeyFromPath("/a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "deadbeef", hashedKey)
+
+	mr.Del("pathToHashedKey:/a.bin")
+
+	hashedKey, err = fp.getHashedKeyFromPath("/a.bin")
+	require.NoError(t, err, "the LRU cache should have served this without touching Redis")
+	assert.Equal(t, "deadbeef", hashedKey)
+}
+
+func TestFileProcessor_CleanUpOldRecords_InvalidatesLRU(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	fp.HashedKeyLRU = NewHashedKeyLRU(0)
+	fp.FileInfoLRU = NewFileInfoLRU(0)
+
+	path := "/does/not/exist/anymore.bin"
+	info := FileInfo{Size: 10, Path: path}
+	require.NoError(t, saveFileInfoToRedis(rdb, ctx, path, info, "partial", "full", true))
+
+	// Warm both LRUs the way ProcessFile's read paths would.
+	hashedKey := generateHash(path)
+	_, _ = fp.getHashedKeyFromPath(path)
+	_, _ = fp.getFileInfoFromRedis(hashedKey)
+
+	require.NoError(t, fp.CleanUpOldRecords())
+
+	_, ok := fp.HashedKeyLRU.Get(getPathToHashedKeyKey(path))
+	assert.False(t, ok, "CleanUpOldRecords should invalidate the stale path->hashedKey LRU entry")
+	_, ok = fp.FileInfoLRU.Get(getFileInfoKey(hashedKey))
+	assert.False(t, ok, "CleanUpOldRecords should invalidate the stale FileInfo LRU entry")
+
+	_ = fs
+}
+
+// BenchmarkGetHashedKeyFromPath_LRU compares the wall-clock cost of repeat
+// path->hashedKey lookups against a populated Redis with and without the
+// in-process LRU in front of it.
+func BenchmarkGetHashedKeyFromPath_LRU(b *testing.B) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	const paths = 1000
+	for i := 0; i < paths; i++ {
+		path := fmt.Sprintf("/bench/file-%d.bin", i)
+		if err := rdb.Set(ctx, "pathToHashedKey:"+path, generateHash(path), 0).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("without_lru", func(b *testing.B) {
+		fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			path := fmt.Sprintf("/bench/file-%d.bin", i%paths)
+			if _, err := fp.getHashedKeyFromPath(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("with_lru", func(b *testing.B) {
+		fp := CreateFileProcessor(rdb, ctx, testExcludeRules, WithLRUCaches(NewHashedKeyLRU(0), NewFileInfoLRU(0)))
+		for i := 0; i < paths; i++ {
+			path := fmt.Sprintf("/bench/file-%d.bin", i)
+			if _, err := fp.getHashedKeyFromPath(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			path := fmt.Sprintf("/bench/file-%d.bin", i%paths)
+			if _, err := fp.getHashedKeyFromPath(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
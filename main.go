@@ -12,26 +12,53 @@ import (
 	"github.com/spf13/afero"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 var (
-	rootDir          string
-	redisAddr        string
-	workerCount      int
-	minSizeBytes     int64
-	deleteDuplicates bool
-	findDuplicates   bool
-	outputDuplicates bool
-	maxDuplicates    int
-	semaphore        chan struct{}
+	rootDir           string
+	redisAddr         string
+	workerCount       int
+	minSizeBytes      int64
+	deleteDuplicates  bool
+	findDuplicates    bool
+	outputDuplicates  bool
+	maxDuplicates     int
+	includePatterns   string
+	tiered            bool
+	shutdownTimeout   time.Duration
+	hashAlgo          string
+	rehash            bool
+	metricsJSONPath   string
+	walkCachePath     string
+	ignoreFileName    string
+	checksumAlgosArg  string
+	checksumAlgos     []string
+	filecacheConfig   string
+	eventsJSONLPath   string
+	findSimilarFiles  bool
+	findSimHashFiles  bool
+	findPartialDups   bool
+	findByteOverlaps  bool
+	migrateHashSchema bool
+	lruHashedKeyMB    int64
+	lruFileInfoMB     int64
+	semaphore         chan struct{}
 )
 
-var excludeRegexps []*regexp.Regexp
+var excludeRules *ExcludeRuleSet
+
+// metrics accumulates counters/phase timings across this process's run (see
+// metrics.go); utils.go's package-level duplicate-scan helpers record into
+// it directly, the same way they already reference the workerCount flag
+// var, while FileProcessor instances record into it via WithMetrics.
+var metrics = NewMetrics()
 
 func init() {
 	flag.StringVar(&rootDir, "rootDir", "", "Root directory to start the search")
@@ -42,9 +69,27 @@ func init() {
 	flag.BoolVar(&findDuplicates, "find-duplicates", false, "Find duplicate files")
 	flag.BoolVar(&outputDuplicates, "output-duplicates", false, "Output duplicate files")
 	flag.IntVar(&maxDuplicates, "max-duplicates", 50, "Maximum number of duplicates to process")
+	flag.StringVar(&includePatterns, "includePatterns", "", "Comma-separated .gitignore-style glob patterns; only matching files are scanned (empty means everything)")
+	flag.BoolVar(&tiered, "tiered", false, "Classify files by size, then head sample, then full hash before declaring duplicates, instead of hashing every file in full")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight files to finish hashing after a SIGINT/SIGTERM before forcing exit")
+	flag.StringVar(&hashAlgo, "hash-algo", string(DefaultHashType), "Hash algorithm to use for deduplication: md5, sha1, sha256, sha512, blake3, or xxh3")
+	flag.BoolVar(&rehash, "rehash", false, "Allow comparing against a Redis dataset hashed with a different -hash-algo, recomputing/overwriting its recorded algorithm")
+	flag.StringVar(&metricsJSONPath, "metrics-json", "", "If set, write the end-of-run metrics report as JSON to this path")
+	flag.StringVar(&walkCachePath, "walk-cache", "", "If set, persist a directory-listing cache to this path and reuse it on the next run to skip unchanged subtrees")
+	flag.StringVar(&ignoreFileName, "ignore-file", "", "If set (e.g. \".gitignore\"), look for this file in every scanned directory and apply its patterns hierarchically, scoped to that directory's subtree, in addition to exclude_patterns.txt")
+	flag.StringVar(&checksumAlgosArg, "checksum-algos", "md5,sha1,sha256,xxh3", "Comma-separated list of algorithms (md5, sha1, sha256, xxh3) to record as a checksums:<hashedKey> Redis hash alongside the dedup hash; empty disables it")
+	flag.StringVar(&filecacheConfig, "filecache-config", "", "If set, path to a [caches.NAME] config file (see filecache.go) declaring local disk caches: partialhash/fullhash are consulted before Redis for hash lookups; fileinfo only receives a copy of each saved FileInfo and is not read back")
+	flag.StringVar(&eventsJSONLPath, "events-jsonl", "", "If set, stream newline-delimited JSON progress events (see events.go) to this path as the scan runs, so external tools can consume progress")
+	flag.BoolVar(&findSimilarFiles, "find-similar", false, "Additionally compute content-defined-chunk hashes (content_cdc.go) for each scanned file and, once the scan finishes, write near-duplicate pairs to fav.log.similar")
+	flag.BoolVar(&findSimHashFiles, "find-simhash", false, "Additionally compute a content SimHash (content_similarity.go) for each scanned file and, once the scan finishes, write near-duplicate pairs to fav.log.similar-content")
+	flag.BoolVar(&findPartialDups, "find-partial-dup", false, "Additionally compute piece hashes (content_pieces.go) for each scanned file and, once the scan finishes, write files sharing large content ranges to fav.log.partial-dup")
+	flag.BoolVar(&findByteOverlaps, "find-byte-overlap", false, "Additionally compute content-defined-chunk offsets (content_cdc_bytes.go) for each scanned file and, once the scan finishes, write byte-weighted near-duplicate pairs to fav.log.byte-overlap")
+	flag.BoolVar(&migrateHashSchema, "migrate-to-hash-schema", false, "Migrate the legacy fileInfo:/hashedKeyToFileHash:/etc. keys to the consolidated file:<hashedKey> Redis hash schema (see redis_hash_schema.go), then exit")
+	flag.Int64Var(&lruHashedKeyMB, "lru-hashedkey-mb", 0, "Byte budget, in MB, for the in-process path->hashedKey/hash LRU cache in front of Redis (see lru_cache.go); 0 uses the package default, negative disables it")
+	flag.Int64Var(&lruFileInfoMB, "lru-fileinfo-mb", 0, "Byte budget, in MB, for the in-process FileInfo LRU cache in front of Redis (see lru_cache.go); 0 uses the package default, negative disables it")
 }
 
-func loadExcludePatterns(filename string, fs afero.Fs) ([]*regexp.Regexp, error) {
+func loadExcludePatterns(filename string, fs afero.Fs) (*ExcludeRuleSet, error) {
 	file, err := fs.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error opening exclude patterns file: %w", err)
@@ -63,16 +108,8 @@ func loadExcludePatterns(filename string, fs afero.Fs) ([]*regexp.Regexp, error)
 	return compileExcludePatterns(patterns)
 }
 
-func compileExcludePatterns(patterns []string) ([]*regexp.Regexp, error) {
-	var regexps []*regexp.Regexp
-	for _, pattern := range patterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			return nil, fmt.Errorf("invalid regex pattern '%s': %v", pattern, err)
-		}
-		regexps = append(regexps, re)
-	}
-	return regexps, nil
+func compileExcludePatterns(patterns []string) (*ExcludeRuleSet, error) {
+	return CompileExcludeRules(patterns)
 }
 
 func main() {
@@ -82,6 +119,14 @@ func main() {
 		log.Fatal("rootDir must be specified")
 	}
 
+	if checksumAlgosArg != "" {
+		for _, algo := range strings.Split(checksumAlgosArg, ",") {
+			if algo = strings.TrimSpace(algo); algo != "" {
+				checksumAlgos = append(checksumAlgos, algo)
+			}
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -90,6 +135,19 @@ func main() {
 	})
 	defer rdb.Close()
 
+	if migrateHashSchema {
+		stats, err := MigrateToHashSchema(rdb, ctx)
+		if err != nil {
+			log.Fatalf("Error migrating to hash schema: %v", err)
+		}
+		log.Printf("Migrated %d file records to the hash schema (%d errors)", stats.Migrated, stats.Errors)
+		return
+	}
+
+	if err := CheckSchemaVersion(rdb, ctx); err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	semaphore = make(chan struct{}, runtime.NumCPU())
 
 	// Load exclude patterns
@@ -101,16 +159,100 @@ func main() {
 	excludePatternsFile := filepath.Join(mainDir, "exclude_patterns.txt")
 	fs := afero.NewOsFs()
 	var err error
-	excludeRegexps, err = loadExcludePatterns(excludePatternsFile, fs)
+	excludeRules, err = loadExcludePatterns(excludePatternsFile, fs)
 	if err != nil {
 		log.Fatalf("Error loading exclude patterns: %v", err)
 	}
-	log.Printf("Loaded %d exclude patterns from %s", len(excludeRegexps), excludePatternsFile)
+	log.Printf("Loaded %d exclude rules from %s", len(excludeRules.rules), excludePatternsFile)
+
+	hashType := HashType(hashAlgo)
+	hasher, ok := hashType.Hasher()
+	if !ok {
+		log.Fatalf("Unknown -hash-algo %q", hashAlgo)
+	}
 
-	fp := CreateFileProcessor(rdb, ctx, excludeRegexps)
+	fpOptions := []func(*FileProcessor){WithHeadHasher(hasher), WithFullHasher(hasher), WithMetrics(metrics)}
+	var hashedKeyLRU, fileInfoLRU LRUCache
+	if lruHashedKeyMB >= 0 {
+		hashedKeyLRU = NewHashedKeyLRU(lruHashedKeyMB * 1024 * 1024)
+	}
+	if lruFileInfoMB >= 0 {
+		fileInfoLRU = NewFileInfoLRU(lruFileInfoMB * 1024 * 1024)
+	}
+	fpOptions = append(fpOptions, WithLRUCaches(hashedKeyLRU, fileInfoLRU))
+	if filecacheConfig != "" {
+		configFile, err := fs.Open(filecacheConfig)
+		if err != nil {
+			log.Fatalf("Error opening filecache config %s: %v", filecacheConfig, err)
+		}
+		cacheConfigs, err := ParseFileCacheConfig(configFile)
+		configFile.Close()
+		if err != nil {
+			log.Fatalf("Error parsing filecache config %s: %v", filecacheConfig, err)
+		}
+		caches, err := NewCaches(fs, cacheConfigs)
+		if err != nil {
+			log.Fatalf("Error building filecache caches: %v", err)
+		}
+		fpOptions = append(fpOptions, WithFileCaches(caches))
+	}
+	if eventsJSONLPath != "" {
+		eventsFile, err := fs.Create(eventsJSONLPath)
+		if err != nil {
+			log.Fatalf("Error creating events-jsonl file %s: %v", eventsJSONLPath, err)
+		}
+		defer eventsFile.Close()
+		fpOptions = append(fpOptions, WithEventSink(NewJSONLSink(eventsFile)))
+	}
+
+	fp := CreateFileProcessor(rdb, ctx, excludeRules, fpOptions...)
 	fp.fs = afero.NewOsFs() // 使用实际文件系统
 
-	if err := CleanUpOldRecords(rdb, ctx); err != nil {
+	if err := fp.CheckDatasetHashAlgo(string(hashType), rehash); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	checkpoint, err := fp.StartOrResumeCheckpoint(rootDir)
+	if err != nil {
+		log.Fatalf("Error loading scan checkpoint: %v", err)
+	}
+	if checkpoint.LastPath != "" {
+		log.Printf("Resuming scan of %s (run %d) from checkpoint after %q", rootDir, checkpoint.RunID, checkpoint.LastPath)
+	}
+
+	// A first SIGINT/SIGTERM cancels ctx so walkFiles and the workers wind
+	// down and a checkpoint is flushed below; a second one forces an
+	// immediate exit in case the in-flight workers don't drain in time.
+	shutdownDone := make(chan struct{})
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		sig, ok := <-sigChan
+		if !ok {
+			return
+		}
+		log.Printf("Received %s, shutting down gracefully (press Ctrl-C again to force exit)...", sig)
+		cancel()
+		select {
+		case sig2 := <-sigChan:
+			log.Printf("Received %s again, forcing exit", sig2)
+			os.Exit(1)
+		case <-shutdownDone:
+		}
+	}()
+
+	for _, pattern := range strings.Split(includePatterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if err := fp.AddIncludePattern(pattern); err != nil {
+			log.Fatalf("Error adding include pattern %q: %v", pattern, err)
+		}
+	}
+
+	if err := fp.CleanUpOldRecords(); err != nil {
 		log.Printf("Error cleaning up old records: %v", err)
 	}
 
@@ -120,6 +262,8 @@ func main() {
 	// 处理文件
 	fileChan := make(chan string, workerCount)
 	var wg sync.WaitGroup
+	var checkpointMu sync.Mutex
+	lastCheckpointPath := checkpoint.LastPath
 
 	// Start worker goroutines
 	for i := 0; i < workerCount; i++ {
@@ -129,9 +273,44 @@ func main() {
 			for relativePath := range fileChan {
 				fullPath := filepath.Join(rootDir, relativePath)
 				if !fp.ShouldExclude(fullPath) {
-					if err := fp.ProcessFile(rootDir, relativePath, calculateHashes); err != nil {
-						log.Printf("Error processing file %s: %v", fullPath, err)
+					var procErr error
+					if tiered {
+						procErr = fp.ProcessFileTiered(fullPath)
+					} else {
+						procErr = fp.ProcessFile(rootDir, relativePath, calculateHashes)
+					}
+					if procErr != nil {
+						log.Printf("Error processing file %s: %v", fullPath, procErr)
+						continue
+					}
+					if findSimilarFiles {
+						if err := fp.ProcessFileCDC(fullPath); err != nil {
+							log.Printf("Warning: error computing CDC chunks for %s: %v", fullPath, err)
+						}
 					}
+					if findSimHashFiles {
+						if err := fp.ProcessFileContentSimilarity(fullPath); err != nil {
+							log.Printf("Warning: error computing content SimHash for %s: %v", fullPath, err)
+						}
+					}
+					if findPartialDups {
+						if err := fp.ProcessFilePieces(fullPath, 0); err != nil {
+							log.Printf("Warning: error computing piece hashes for %s: %v", fullPath, err)
+						}
+					}
+					if findByteOverlaps {
+						if err := fp.ProcessFileChunkOffsets(fullPath); err != nil {
+							log.Printf("Warning: error computing chunk offsets for %s: %v", fullPath, err)
+						}
+					}
+					if err := fp.MarkProcessed(checkpoint.RunID, relativePath); err != nil {
+						log.Printf("Warning: %v", err)
+					}
+					checkpointMu.Lock()
+					if relativePath > lastCheckpointPath {
+						lastCheckpointPath = relativePath
+					}
+					checkpointMu.Unlock()
 				}
 			}
 		}()
@@ -140,19 +319,72 @@ func main() {
 	// Start progress monitoring
 	go monitorProgress(ctx)
 
-	err = walkFiles(rootDir, minSizeBytes, fileChan, fp)
+	var walkCache, nextWalkCache *WalkCache
+	if walkCachePath != "" {
+		walkCache, err = LoadWalkCache(fp.fs, walkCachePath)
+		if err != nil {
+			log.Fatalf("Error loading walk cache: %v", err)
+		}
+	}
+
+	scanPhase := metrics.StartPhase("walk_and_hash")
+	switch {
+	case ignoreFileName != "":
+		err = walkFilesWithIgnore(ctx, rootDir, minSizeBytes, fileChan, fp, checkpoint, ignoreFileName)
+	case walkCachePath != "":
+		nextWalkCache, err = walkFilesCached(ctx, rootDir, minSizeBytes, fileChan, fp, checkpoint, walkCache)
+	default:
+		err = walkFiles(ctx, rootDir, minSizeBytes, fileChan, fp, checkpoint)
+	}
 	close(fileChan)
 	if err != nil {
 		log.Printf("Error walking files: %v", err)
 	}
 
-	wg.Wait()
+	if !waitForWorkers(&wg, shutdownTimeout) {
+		log.Printf("Timed out after %s waiting for in-flight files to finish; forcing exit", shutdownTimeout)
+		os.Exit(1)
+	}
+	scanPhase.Stop()
+	close(shutdownDone)
+
+	if ctx.Err() != nil {
+		checkpointMu.Lock()
+		finalPath := lastCheckpointPath
+		checkpointMu.Unlock()
+		if err := fp.SaveCheckpoint(rootDir, Checkpoint{RunID: checkpoint.RunID, LastPath: finalPath}); err != nil {
+			log.Printf("Error saving checkpoint: %v", err)
+		}
+		log.Printf("Scan of %s interrupted; re-run with the same rootDir to resume from %q", rootDir, finalPath)
+		return
+	}
+
+	if err := fp.ClearCheckpoint(rootDir, checkpoint.RunID); err != nil {
+		log.Printf("Error clearing checkpoint: %v", err)
+	}
+
+	if walkCachePath != "" && nextWalkCache != nil {
+		if err := SaveWalkCache(fp.fs, walkCachePath, nextWalkCache, fp.KeepRotations); err != nil {
+			log.Printf("Error saving walk cache: %v", err)
+		}
+	}
 
 	// 在处理完文件后，根据标志执行相应操作
 	if findDuplicates {
-		if err := findAndLogDuplicates(rootDir, rdb, ctx, maxDuplicates, excludeRegexps, fp.fs); err != nil {
+		dedupPhase := metrics.StartPhase("find_duplicates")
+		if tiered {
+			groups, stats, err := fp.FindDuplicatesTiered()
+			if err != nil {
+				log.Fatalf("Error finding duplicates (tiered): %v", err)
+			}
+			metrics.AddEliminatedBySize(int64(stats.EliminatedBySize))
+			metrics.AddEliminatedByHead(int64(stats.EliminatedByHead))
+			log.Printf("Tiered duplicate search: %d files considered, %d eliminated by size, %d eliminated by head sample, %d full hashes computed, %d duplicate groups found",
+				stats.FilesConsidered, stats.EliminatedBySize, stats.EliminatedByHead, stats.FullHashesComputed, len(groups))
+		} else if err := findAndLogDuplicates(rootDir, rdb, ctx, maxDuplicates, excludeRules, fp.fs, hashType); err != nil {
 			log.Fatalf("Error finding duplicates: %v", err)
 		}
+		dedupPhase.Stop()
 	}
 
 	if outputDuplicates {
@@ -161,27 +393,70 @@ func main() {
 		}
 	}
 
+	if findSimilarFiles {
+		if err := fp.WriteCDCSimilarFilesToFile(rootDir, "fav.log.similar", 0); err != nil {
+			log.Fatalf("Error writing similar files: %v", err)
+		}
+	}
+
+	if findSimHashFiles {
+		if err := fp.WriteSimilarFilesToFile(rootDir, "fav.log.similar-content", 0); err != nil {
+			log.Fatalf("Error writing SimHash-similar files: %v", err)
+		}
+	}
+
+	if findPartialDups {
+		if err := fp.WritePartialDuplicatesToFile(rootDir, "fav.log.partial-dup", 0); err != nil {
+			log.Fatalf("Error writing partial duplicates: %v", err)
+		}
+	}
+
+	if findByteOverlaps {
+		if err := fp.WriteByteOverlapsToFile(rootDir, "fav.log.byte-overlap", 0); err != nil {
+			log.Fatalf("Error writing byte overlaps: %v", err)
+		}
+	}
+
 	if deleteDuplicates {
-		if err := deleteDuplicateFiles(rootDir, rdb, ctx, fp.fs); err != nil {
+		deletePhase := metrics.StartPhase("delete_duplicates")
+		err := deleteDuplicateFiles(rootDir, rdb, ctx, fp.fs, excludeRules)
+		deletePhase.Stop()
+		if err != nil {
 			log.Fatalf("Error deleting duplicate files: %v", err)
 		}
 	}
 
 	log.Println("Processing complete")
+	log.Println(metrics.Snapshot().String())
+	if metricsJSONPath != "" {
+		if err := metrics.WriteJSON(metricsJSONPath); err != nil {
+			log.Printf("Error writing metrics JSON: %v", err)
+		}
+	}
 }
 
 // 更新 walkFiles 函数
-func walkFiles(rootDir string, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor) error {
+//
+// walkFiles stops as soon as ctx is cancelled (a SIGINT/SIGTERM), returning
+// ctx.Err(). When checkpoint carries a non-empty LastPath, entries a prior,
+// interrupted run of the same rootDir already finished are skipped via
+// FileProcessor.ShouldSkipForResume rather than re-enqueued.
+func walkFiles(ctx context.Context, rootDir string, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor, checkpoint *Checkpoint) error {
 	return filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		if err != nil {
 			log.Printf("Error accessing path %q: %v", path, err)
 			return filepath.SkipDir
 		}
 
-		if fp.ShouldExclude(path) {
+		if fp.ShouldExcludeInfo(path, info.IsDir()) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
+			metrics.IncFilesSkippedExcluded()
 			return nil
 		}
 
@@ -200,13 +475,45 @@ func walkFiles(rootDir string, minSizeBytes int64, fileChan chan<- string, fp *F
 				log.Printf("Error getting relative path for %q: %v", path, err)
 				return nil
 			}
-			fileChan <- relPath
+
+			skip, err := fp.ShouldSkipForResume(checkpoint, relPath)
+			if err != nil {
+				log.Printf("Error checking resume state for %q: %v", relPath, err)
+			} else if skip {
+				return nil
+			}
+
+			select {
+			case fileChan <- relPath:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else {
+			metrics.IncFilesSkippedMinSize()
 		}
 
 		return nil
 	})
 }
 
+// waitForWorkers waits for wg to finish, up to timeout, and reports whether
+// it finished in time. Used after a SIGINT/SIGTERM cancels ctx so a worker
+// stuck on a huge file doesn't block shutdown forever.
+func waitForWorkers(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 // 初始化Redis客户端
 func newRedisClient(ctx context.Context) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
@@ -229,8 +536,7 @@ func monitorProgress(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// You might want to implement a way to track progress
-			log.Println("Processing files...")
+			log.Println(metrics.Snapshot().String())
 		}
 	}
 }
@@ -0,0 +1,442 @@
+// meta_store.go
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+)
+
+// MetaStore abstracts the slice of Redis functionality FileProcessor relies
+// on (string KV, sets, and sorted sets) behind an interface, modeled on how
+// afero.Fs abstracts the filesystem elsewhere in this package. This lets the
+// scanner's metadata live somewhere other than a live Redis server.
+//
+// Only redisMetaStore, memMetaStore, and fileMetaStore are wired in so far;
+// FileProcessor itself still talks to *redis.Client directly throughout -
+// migrating every Get/Set/ZAdd call site onto MetaStore is substantial and
+// tracked as follow-up work rather than bundled into this change.
+type MetaStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+
+	SAdd(ctx context.Context, key string, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SIsMember(ctx context.Context, key, member string) (bool, error)
+
+	ZAdd(ctx context.Context, key string, score float64, member string) error
+	ZRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	ZRangeByScore(ctx context.Context, key string, min, max string) ([]string, error)
+
+	// ScanKeys returns every key matching a Redis SCAN-style glob pattern
+	// ('*' any run of characters, '?' exactly one). Real Redis callers in
+	// this package always drain the cursor-based SCAN fully anyway, so
+	// backends that can't paginate cheaply just filter their whole keyspace.
+	ScanKeys(ctx context.Context, pattern string) ([]string, error)
+}
+
+// redisMetaStore adapts a *redis.Client to MetaStore.
+type redisMetaStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisMetaStore wraps an existing Redis client as a MetaStore.
+func NewRedisMetaStore(rdb *redis.Client) MetaStore {
+	return &redisMetaStore{rdb: rdb}
+}
+
+func (s *redisMetaStore) Get(ctx context.Context, key string) (string, error) {
+	return s.rdb.Get(ctx, key).Result()
+}
+
+func (s *redisMetaStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *redisMetaStore) Del(ctx context.Context, keys ...string) error {
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
+func (s *redisMetaStore) SAdd(ctx context.Context, key string, members ...string) error {
+	args := make([]interface{}, len(members))
+	for i, m := range members {
+		args[i] = m
+	}
+	return s.rdb.SAdd(ctx, key, args...).Err()
+}
+
+func (s *redisMetaStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	return s.rdb.SMembers(ctx, key).Result()
+}
+
+func (s *redisMetaStore) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	return s.rdb.SIsMember(ctx, key, member).Result()
+}
+
+func (s *redisMetaStore) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return s.rdb.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Err()
+}
+
+func (s *redisMetaStore) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return s.rdb.ZRange(ctx, key, start, stop).Result()
+}
+
+func (s *redisMetaStore) ZRangeByScore(ctx context.Context, key string, min, max string) ([]string, error) {
+	return s.rdb.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+}
+
+func (s *redisMetaStore) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	iter := s.rdb.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning keys matching %q: %w", pattern, err)
+	}
+	return keys, nil
+}
+
+// memMetaStore is an in-memory MetaStore, useful for tests that don't want
+// to spin up miniredis just to exercise KV/set/sorted-set logic. It ignores
+// TTLs - everything lives for the life of the process.
+type memMetaStore struct {
+	mu      sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]struct{}
+	zsets   map[string]map[string]float64
+}
+
+// NewMemMetaStore returns an empty in-memory MetaStore.
+func NewMemMetaStore() MetaStore {
+	return &memMetaStore{
+		strings: make(map[string]string),
+		sets:    make(map[string]map[string]struct{}),
+		zsets:   make(map[string]map[string]float64),
+	}
+}
+
+func (s *memMetaStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.strings[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return v, nil
+}
+
+func (s *memMetaStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strings[key] = value
+	return nil
+}
+
+func (s *memMetaStore) Del(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.strings, key)
+		delete(s.sets, key)
+		delete(s.zsets, key)
+	}
+	return nil
+}
+
+func (s *memMetaStore) SAdd(ctx context.Context, key string, members ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.sets[key]
+	if !ok {
+		set = make(map[string]struct{})
+		s.sets[key] = set
+	}
+	for _, m := range members {
+		set[m] = struct{}{}
+	}
+	return nil
+}
+
+func (s *memMetaStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := make([]string, 0, len(s.sets[key]))
+	for m := range s.sets[key] {
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+func (s *memMetaStore) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sets[key][member]
+	return ok, nil
+}
+
+func (s *memMetaStore) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zsets[key]
+	if !ok {
+		z = make(map[string]float64)
+		s.zsets[key] = z
+	}
+	z[member] = score
+	return nil
+}
+
+func (s *memMetaStore) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := sortedZSetMembers(s.zsets[key])
+	lo, hi, ok := normalizeRange(start, stop, int64(len(members)))
+	if !ok {
+		return []string{}, nil
+	}
+	return append([]string{}, members[lo:hi]...), nil
+}
+
+func (s *memMetaStore) ZRangeByScore(ctx context.Context, key string, min, max string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	minScore, err := parseScoreBound(min, math.Inf(-1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid min score %q: %w", min, err)
+	}
+	maxScore, err := parseScoreBound(max, math.Inf(1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid max score %q: %w", max, err)
+	}
+
+	z := s.zsets[key]
+	out := []string{}
+	for _, m := range sortedZSetMembers(z) {
+		if score := z[m]; score >= minScore && score <= maxScore {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (s *memMetaStore) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]struct{})
+	var keys []string
+	collect := func(k string) {
+		if _, ok := seen[k]; ok || !redisGlobMatch(pattern, k) {
+			return
+		}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+	}
+	for k := range s.strings {
+		collect(k)
+	}
+	for k := range s.sets {
+		collect(k)
+	}
+	for k := range s.zsets {
+		collect(k)
+	}
+	return keys, nil
+}
+
+// sortedZSetMembers returns z's members ordered by ascending score, then by
+// member name to break ties deterministically (mirroring Redis's ZRANGE
+// ordering for equal scores).
+func sortedZSetMembers(z map[string]float64) []string {
+	members := make([]string, 0, len(z))
+	for m := range z {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if z[members[i]] != z[members[j]] {
+			return z[members[i]] < z[members[j]]
+		}
+		return members[i] < members[j]
+	})
+	return members
+}
+
+// normalizeRange converts Redis-style (possibly negative) start/stop
+// indices into a Go slice range [lo, hi) over a sequence of length n.
+func normalizeRange(start, stop, n int64) (lo, hi int64, ok bool) {
+	if n == 0 {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return start, stop + 1, true
+}
+
+// parseScoreBound parses a ZRANGEBYSCORE-style bound: "-inf", "+inf", "" (the
+// caller's default), or a plain float.
+func parseScoreBound(raw string, defaultValue float64) (float64, error) {
+	switch raw {
+	case "-inf":
+		return math.Inf(-1), nil
+	case "+inf":
+		return math.Inf(1), nil
+	case "":
+		return defaultValue, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// redisGlobMatch reports whether s matches pattern using Redis SCAN's MATCH
+// glob syntax ('*' matches any run of characters, '?' matches exactly one).
+func redisGlobMatch(pattern, s string) bool {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String()).MatchString(s)
+}
+
+// fileMetaStoreSnapshot is the gob-encoded form fileMetaStore persists to
+// disk.
+type fileMetaStoreSnapshot struct {
+	Strings map[string]string
+	Sets    map[string]map[string]struct{}
+	ZSets   map[string]map[string]float64
+}
+
+// fileMetaStore is a single-file, gob-encoded MetaStore for zero-dependency,
+// single-user runs where standing up a Redis server isn't worth it. It reuses
+// memMetaStore's in-memory maps and flushes a full snapshot to disk (via
+// atomicWriteFile, so a write is never left half-done) after every mutation.
+//
+// This stands in for a genuine embedded KV engine such as BoltDB: no such
+// dependency is vendored in this build, and a single gob-encoded snapshot is
+// good enough for the single-user case this store targets.
+type fileMetaStore struct {
+	*memMetaStore
+	fs   afero.Fs
+	path string
+}
+
+// NewFileMetaStore opens (or creates) a gob-encoded MetaStore at path on fs,
+// loading any existing snapshot.
+func NewFileMetaStore(fs afero.Fs, path string) (MetaStore, error) {
+	store := &fileMetaStore{
+		memMetaStore: &memMetaStore{
+			strings: make(map[string]string),
+			sets:    make(map[string]map[string]struct{}),
+			zsets:   make(map[string]map[string]float64),
+		},
+		fs:   fs,
+		path: path,
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *fileMetaStore) load() error {
+	exists, err := afero.Exists(s.fs, s.path)
+	if err != nil {
+		return fmt.Errorf("error checking metastore file %s: %w", s.path, err)
+	}
+	if !exists {
+		return nil
+	}
+
+	f, err := s.fs.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("error opening metastore file %s: %w", s.path, err)
+	}
+	defer f.Close()
+
+	var snapshot fileMetaStoreSnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return fmt.Errorf("error decoding metastore file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strings = snapshot.Strings
+	s.sets = snapshot.Sets
+	s.zsets = snapshot.ZSets
+	return nil
+}
+
+func (s *fileMetaStore) save() error {
+	s.mu.Lock()
+	snapshot := fileMetaStoreSnapshot{Strings: s.strings, Sets: s.sets, ZSets: s.zsets}
+	s.mu.Unlock()
+
+	return atomicWriteFile(s.fs, s.path, defaultKeepRotations, false, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(snapshot)
+	})
+}
+
+func (s *fileMetaStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := s.memMetaStore.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *fileMetaStore) Del(ctx context.Context, keys ...string) error {
+	if err := s.memMetaStore.Del(ctx, keys...); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *fileMetaStore) SAdd(ctx context.Context, key string, members ...string) error {
+	if err := s.memMetaStore.SAdd(ctx, key, members...); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *fileMetaStore) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	if err := s.memMetaStore.ZAdd(ctx, key, score, member); err != nil {
+		return err
+	}
+	return s.save()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStores returns one MetaStore per backend so the tests below can run
+// the same assertions against all of them.
+func newTestStores(t *testing.T) []struct {
+	name  string
+	store MetaStore
+} {
+	fs := afero.NewMemMapFs()
+	fileStore, err := NewFileMetaStore(fs, "/meta.gob")
+	require.NoError(t, err)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return []struct {
+		name  string
+		store MetaStore
+	}{
+		{"mem", NewMemMetaStore()},
+		{"file", fileStore},
+		{"redis", NewRedisMetaStore(rdb)},
+	}
+}
+
+func TestMetaStoreStringOps(t *testing.T) {
+	for _, tc := range newTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.store
+
+			_, err := store.Get(ctx, "missing")
+			assert.ErrorIs(t, err, redis.Nil)
+
+			require.NoError(t, store.Set(ctx, "k", "v", 0))
+			v, err := store.Get(ctx, "k")
+			require.NoError(t, err)
+			assert.Equal(t, "v", v)
+
+			require.NoError(t, store.Del(ctx, "k"))
+			_, err = store.Get(ctx, "k")
+			assert.ErrorIs(t, err, redis.Nil)
+		})
+	}
+}
+
+func TestMetaStoreSetOps(t *testing.T) {
+	for _, tc := range newTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.store
+
+			require.NoError(t, store.SAdd(ctx, "myset", "a", "b"))
+
+			members, err := store.SMembers(ctx, "myset")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "b"}, members)
+
+			ok, err := store.SIsMember(ctx, "myset", "a")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = store.SIsMember(ctx, "myset", "z")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestMetaStoreSortedSetOps(t *testing.T) {
+	for _, tc := range newTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.store
+
+			require.NoError(t, store.ZAdd(ctx, "myzset", 3, "three"))
+			require.NoError(t, store.ZAdd(ctx, "myzset", 1, "one"))
+			require.NoError(t, store.ZAdd(ctx, "myzset", 2, "two"))
+
+			all, err := store.ZRange(ctx, "myzset", 0, -1)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"one", "two", "three"}, all)
+
+			byScore, err := store.ZRangeByScore(ctx, "myzset", "2", "+inf")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"two", "three"}, byScore)
+		})
+	}
+}
+
+func TestMetaStoreScanKeys(t *testing.T) {
+	for _, tc := range newTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.store
+
+			require.NoError(t, store.Set(ctx, "fileInfo:abc", "1", 0))
+			require.NoError(t, store.Set(ctx, "fileInfo:def", "2", 0))
+			require.NoError(t, store.Set(ctx, "other:xyz", "3", 0))
+
+			keys, err := store.ScanKeys(ctx, "fileInfo:*")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"fileInfo:abc", "fileInfo:def"}, keys)
+		})
+	}
+}
+
+func TestFileMetaStorePersistsAcrossReload(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	ctx := context.Background()
+
+	store, err := NewFileMetaStore(fs, "/meta.gob")
+	require.NoError(t, err)
+	require.NoError(t, store.Set(ctx, "k", "v", 0))
+	require.NoError(t, store.SAdd(ctx, "s", "a"))
+	require.NoError(t, store.ZAdd(ctx, "z", 1, "m"))
+
+	reloaded, err := NewFileMetaStore(fs, "/meta.gob")
+	require.NoError(t, err)
+
+	v, err := reloaded.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "v", v)
+
+	members, err := reloaded.SMembers(ctx, "s")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, members)
+
+	zmembers, err := reloaded.ZRange(ctx, "z", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m"}, zmembers)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_NilReceiverIsNoOp(t *testing.T) {
+	var m *Metrics
+	m.IncFilesConsidered()
+	m.IncFilesSkippedExcluded()
+	m.IncFilesSkippedMinSize()
+	m.AddBytesReadPartial(10)
+	m.AddBytesReadFull(10)
+	m.AddEliminatedBySize(1)
+	m.AddEliminatedByHead(1)
+	m.RecordDuplicateGroup(100, 3)
+	m.IncFilesDeleted()
+	assert.Equal(t, Report{}, m.Snapshot())
+
+	h := m.StartPhase("noop")
+	h.Stop()
+}
+
+func TestMetrics_CountersAccumulate(t *testing.T) {
+	m := NewMetrics()
+	m.IncFilesConsidered()
+	m.IncFilesConsidered()
+	m.IncFilesSkippedExcluded()
+	m.AddBytesReadFull(512)
+	m.RecordDuplicateGroup(100, 3)
+
+	r := m.Snapshot()
+	assert.EqualValues(t, 2, r.FilesConsidered)
+	assert.EqualValues(t, 1, r.FilesSkippedExcluded)
+	assert.EqualValues(t, 512, r.BytesReadFull)
+	assert.EqualValues(t, 1, r.DuplicateGroups)
+	assert.EqualValues(t, 200, r.DuplicateBytes)
+}
+
+func TestMetrics_PhaseAccumulatesAcrossCalls(t *testing.T) {
+	m := NewMetrics()
+	m.StartPhase("walk_and_hash").Stop()
+	m.StartPhase("walk_and_hash").Stop()
+
+	r := m.Snapshot()
+	require.Len(t, r.Phases, 1)
+	assert.Equal(t, "walk_and_hash", r.Phases[0].Name)
+}
+
+func TestMetrics_WriteJSON(t *testing.T) {
+	m := NewMetrics()
+	m.IncFilesConsidered()
+
+	path := t.TempDir() + "/metrics.json"
+	require.NoError(t, m.WriteJSON(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\"files_considered\": 1")
+}
+
+func TestReport_StringIncludesPhases(t *testing.T) {
+	m := NewMetrics()
+	m.StartPhase("find_duplicates").Stop()
+
+	s := m.Snapshot().String()
+	assert.Contains(t, s, "files:")
+	assert.Contains(t, s, "phase find_duplicates:")
+}
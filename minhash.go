@@ -0,0 +1,160 @@
+// minhash.go
+package main
+
+import (
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	minHashFuncs   = 64 // number of hash functions in a MinHash signature
+	minHashBands   = 16 // bands used for LSH bucketing
+	minHashRows    = minHashFuncs / minHashBands
+	shingleSize    = 3 // size (in runes) of the shingles used to build signatures
+	lshMaxUint64   = ^uint64(0)
+	minHashSeedMul = uint64(0x9E3779B97F4A7C15) // golden-ratio constant used to derive per-function seeds
+)
+
+// shingles splits name into overlapping k-runes substrings ("shingles").
+// Names shorter than shingleSize are treated as a single shingle so very
+// short names still participate in similarity matching.
+func shingles(name string, k int) map[string]struct{} {
+	runes := []rune(name)
+	set := make(map[string]struct{})
+	if len(runes) <= k {
+		set[string(runes)] = struct{}{}
+		return set
+	}
+	for i := 0; i+k <= len(runes); i++ {
+		set[string(runes[i:i+k])] = struct{}{}
+	}
+	return set
+}
+
+// minHashSignature computes a MinHash signature over a set of shingles using
+// minHashFuncs independent hash functions derived from a single FNV hash via
+// seed mixing, avoiding the cost of instantiating many hash.Hash64 objects.
+func minHashSignature(set map[string]struct{}) [minHashFuncs]uint64 {
+	var sig [minHashFuncs]uint64
+	for i := range sig {
+		sig[i] = lshMaxUint64
+	}
+
+	for shingle := range set {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+
+		for i := 0; i < minHashFuncs; i++ {
+			seed := base ^ (uint64(i+1) * minHashSeedMul)
+			v := mixUint64(seed)
+			if v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+
+	return sig
+}
+
+// mixUint64 is a small, fast integer mixer (splitmix64-style) used to derive
+// independent-looking hash values from a single base hash plus a seed.
+func mixUint64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
+
+// lshIndex buckets MinHash signatures by band so that candidate pairs can be
+// retrieved without comparing every file against every other file.
+type lshIndex struct {
+	buckets [minHashBands]map[string][]int
+}
+
+func newLSHIndex() *lshIndex {
+	idx := &lshIndex{}
+	for b := range idx.buckets {
+		idx.buckets[b] = make(map[string][]int)
+	}
+	return idx
+}
+
+func (idx *lshIndex) add(i int, sig [minHashFuncs]uint64) {
+	for b := 0; b < minHashBands; b++ {
+		key := bandKey(sig, b)
+		idx.buckets[b][key] = append(idx.buckets[b][key], i)
+	}
+}
+
+// candidates returns the set of file indices that share at least one band
+// bucket with i, excluding i itself.
+func (idx *lshIndex) candidates(i int, sig [minHashFuncs]uint64) map[int]struct{} {
+	out := make(map[int]struct{})
+	for b := 0; b < minHashBands; b++ {
+		key := bandKey(sig, b)
+		for _, j := range idx.buckets[b][key] {
+			if j != i {
+				out[j] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+func bandKey(sig [minHashFuncs]uint64, band int) string {
+	start := band * minHashRows
+	var sb strings.Builder
+	for _, v := range sig[start : start+minHashRows] {
+		sb.WriteString(strconv.FormatUint(v, 36))
+		sb.WriteByte(':')
+	}
+	return sb.String()
+}
+
+// minhashIndex is a SimilarityIndex backed by MinHash signatures bucketed
+// into an LSH index. It was CloseFileFinder's default prefilter before the
+// bkTreeIndex; kept as a selectable WithSimilarityIndex(minhashIndex{})
+// option since it trades exact recall for sublinear candidate generation on
+// very large inputs where the BK-tree's per-query traversal cost adds up.
+type minhashIndex struct{}
+
+func (minhashIndex) CandidatePairs(names []string, _ float64) [][2]int {
+	signatures := make([][minHashFuncs]uint64, len(names))
+	idx := newLSHIndex()
+
+	for i, name := range names {
+		signatures[i] = minHashSignature(shingles(name, shingleSize))
+		idx.add(i, signatures[i])
+	}
+
+	seen := make(map[[2]int]struct{})
+	var pairs [][2]int
+	for i := range names {
+		for j := range idx.candidates(i, signatures[i]) {
+			pair := [2]int{i, j}
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if _, ok := seen[pair]; ok {
+				continue
+			}
+			seen[pair] = struct{}{}
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
+
+// normalizedBaseName mirrors the normalization calculateSimilarity already
+// applies so shingles are built from the same text that is compared.
+func normalizedBaseName(path string, opts NormalizeOptions) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return NormalizeFileName(name, opts)
+}
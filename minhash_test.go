@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinHashSignatureDeterministic(t *testing.T) {
+	set := shingles("similar_name_1", shingleSize)
+	sig1 := minHashSignature(set)
+	sig2 := minHashSignature(set)
+	assert.Equal(t, sig1, sig2)
+}
+
+func TestMinHashSignatureSimilarNamesShareBands(t *testing.T) {
+	opts := DefaultNormalizeOptions()
+	sigA := minHashSignature(shingles(normalizedBaseName("similar_name_1", opts), shingleSize))
+	sigB := minHashSignature(shingles(normalizedBaseName("similar_name_2", opts), shingleSize))
+	sigC := minHashSignature(shingles(normalizedBaseName("totally_different", opts), shingleSize))
+
+	idx := newLSHIndex()
+	idx.add(0, sigA)
+	idx.add(1, sigB)
+	idx.add(2, sigC)
+
+	candidatesForA := idx.candidates(0, sigA)
+	_, sharesWithB := candidatesForA[1]
+	_, sharesWithC := candidatesForA[2]
+
+	assert.True(t, sharesWithB, "near-identical names should collide in at least one LSH band")
+	assert.False(t, sharesWithC, "unrelated names should not collide")
+}
+
+func TestCandidatePairsExcludesSelfAndDuplicates(t *testing.T) {
+	finder := NewCloseFileFinder("/unused", WithSimilarityIndex(minhashIndex{}))
+	files := []string{"similar_name_1.mp4", "similar_name_2.mp4", "totally_different.txt"}
+
+	pairs := finder.candidatePairs(files)
+	for _, pair := range pairs {
+		assert.Less(t, pair[0], pair[1])
+	}
+}
+
+func TestMinhashIndexCandidatePairsFindsSimilarNames(t *testing.T) {
+	pairs := minhashIndex{}.CandidatePairs([]string{"similar_name_1", "similar_name_2", "totally_different"}, 0.6)
+	found := false
+	for _, pair := range pairs {
+		if pair == [2]int{0, 1} {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,321 @@
+// multi_hash.go
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefixMultiHashes = "hashedKeyToMultiHash:"
+
+// keyPrefixMultiHashToPath namespaces the reverse index MultiHasher duplicate
+// lookups use by algorithm, so two files sharing an MD5 collision don't show
+// up as sharing a SHA-256 one too.
+const keyPrefixMultiHashToPath = "multiHashToPathSet:"
+
+func getMultiHashesKey(hashedKey string) string {
+	return keyPrefixMultiHashes + hashedKey
+}
+
+func getMultiHashToPathSetKey(algo, digest string) string {
+	return keyPrefixMultiHashToPath + algo + ":" + digest
+}
+
+// MultiHashes is the gob-encoded value stored under
+// hashedKeyToMultiHash:<hashedKey>: every algorithm's hex digest for one
+// file, keyed by algorithm name.
+type MultiHashes struct {
+	Hashes map[string]string
+}
+
+// DuplicateMatchMode selects how FindMultiHashDuplicates decides two files
+// are duplicates when several algorithms were computed for them.
+type DuplicateMatchMode int
+
+const (
+	// MatchAnyAlgorithm groups files that share a matching digest under at
+	// least one configured algorithm - the most permissive mode, useful for
+	// surfacing a possible collision for manual review.
+	MatchAnyAlgorithm DuplicateMatchMode = iota
+	// MatchAllAlgorithms requires every configured algorithm's digest to
+	// match, the strongest cross-verification: a collision in one algorithm
+	// alone isn't enough to call two files duplicates.
+	MatchAllAlgorithms
+	// MatchSpecificAlgorithm groups files solely by DuplicateMatchAlgorithm,
+	// ignoring any other algorithm FileProcessor.Algorithms also computed.
+	MatchSpecificAlgorithm
+)
+
+// computeMultiHash reads fullPath once and returns the hex digest each of
+// algorithms produced, computed in a single pass via io.MultiWriter rather
+// than re-reading the file once per algorithm.
+func (fp *FileProcessor) computeMultiHash(fullPath string, limit int64, algorithms []string) (map[string]string, error) {
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("no hash algorithms configured")
+	}
+
+	hashers := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, name := range algorithms {
+		h, ok := LookupHasher(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q", name)
+		}
+		hh := h.New()
+		hashers[name] = hh
+		writers = append(writers, hh)
+	}
+
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file for multi-hash: %w", err)
+	}
+	defer f.Close()
+
+	mw := io.MultiWriter(writers...)
+	buf := make([]byte, 32*1024)
+	if limit == FullFileReadCmd {
+		if _, err := io.CopyBuffer(mw, f, buf); err != nil {
+			return nil, fmt.Errorf("error reading %s for multi-hash: %w", fullPath, err)
+		}
+	} else {
+		if _, err := io.CopyN(mw, f, limit); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading %s for multi-hash: %w", fullPath, err)
+		}
+	}
+
+	digests := make(map[string]string, len(algorithms))
+	for name, hh := range hashers {
+		digests[name] = hex.EncodeToString(hh.Sum(nil))
+	}
+	return digests, nil
+}
+
+// ProcessFileMultiHash computes every algorithm in fp.Algorithms (see
+// WithHashAlgorithms) for fullPath in a single file pass and persists all of
+// them atomically: the combined MultiHashes record, each algorithm's
+// per-file cache entry, and each algorithm's reverse index used by
+// FindMultiHashDuplicates. A FileProcessor with no configured algorithms is
+// a no-op, so callers that don't need cross-verification pay nothing extra
+// beyond ProcessFile/calculateFileHash's existing single-algorithm hash.
+func (fp *FileProcessor) ProcessFileMultiHash(fullPath string) error {
+	if len(fp.Algorithms) == 0 {
+		return nil
+	}
+
+	digests, err := fp.computeMultiHash(fullPath, FullFileReadCmd, fp.Algorithms)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(MultiHashes{Hashes: digests}); err != nil {
+		return fmt.Errorf("error encoding multi-hash record for %s: %w", fullPath, err)
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+
+	pipe := fp.Rdb.TxPipeline()
+	pipe.Set(fp.Ctx, getMultiHashesKey(hashedKey), buf.Bytes(), 0)
+	for algo, digest := range digests {
+		pipe.Set(fp.Ctx, getFullHashCacheKeyForAlgo(hashedKey, algo), digest, 0)
+		pipe.SAdd(fp.Ctx, getMultiHashToPathSetKey(algo, digest), fullPath)
+	}
+	if _, err := pipe.Exec(fp.Ctx); err != nil {
+		return fmt.Errorf("error saving multi-hash record for %s: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// getMultiHashes reads back the MultiHashes stored for hashedKey.
+func (fp *FileProcessor) getMultiHashes(hashedKey string) (MultiHashes, error) {
+	var hashes MultiHashes
+	value, err := fp.Rdb.Get(fp.Ctx, getMultiHashesKey(hashedKey)).Bytes()
+	if err != nil {
+		return hashes, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&hashes); err != nil {
+		return hashes, fmt.Errorf("error decoding multi-hash record: %w", err)
+	}
+	return hashes, nil
+}
+
+// scanMultiHashes returns every path -> MultiHashes pair currently stored,
+// resolved through hashedKeyToPath the same way scanCDCChunks and
+// scanPieceHashes do.
+func (fp *FileProcessor) scanMultiHashes() (map[string]MultiHashes, error) {
+	result := make(map[string]MultiHashes)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, keyPrefixMultiHashes+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixMultiHashes)
+
+		hashes, err := fp.getMultiHashes(hashedKey)
+		if err != nil {
+			continue
+		}
+
+		path, err := fp.Rdb.Get(fp.Ctx, getHashedKeyToPathKey(hashedKey)).Result()
+		if err != nil {
+			continue
+		}
+
+		result[path] = hashes
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning multi-hash records: %w", err)
+	}
+	return result, nil
+}
+
+// MultiHashDuplicateGroup is a set of paths FindMultiHashDuplicates
+// considers duplicates under its DuplicateMatchMode. Algorithm and Digest
+// are only meaningful for MatchSpecificAlgorithm/MatchAnyAlgorithm, where a
+// single algorithm+digest pair explains the match; MatchAllAlgorithms groups
+// can differ by which algorithm a reader cares to inspect, so both are left
+// blank there.
+type MultiHashDuplicateGroup struct {
+	Algorithm string
+	Digest    string
+	Paths     []string
+}
+
+// FindMultiHashDuplicates groups files with recorded MultiHashes according
+// to mode:
+//   - MatchSpecificAlgorithm groups by specificAlgorithm's digest alone.
+//   - MatchAnyAlgorithm groups files sharing a digest under any one
+//     configured algorithm, the same reverse-index lookup
+//     MatchSpecificAlgorithm uses, just across every algorithm.
+//   - MatchAllAlgorithms starts from the MatchAnyAlgorithm candidate groups
+//     and keeps only the subsets whose members agree on every algorithm,
+//     the strongest form of cross-verification.
+func (fp *FileProcessor) FindMultiHashDuplicates(mode DuplicateMatchMode, specificAlgorithm string) ([]MultiHashDuplicateGroup, error) {
+	pathToHashes, err := fp.scanMultiHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case MatchSpecificAlgorithm:
+		return fp.groupByAlgorithm(pathToHashes, specificAlgorithm)
+	case MatchAllAlgorithms:
+		return fp.groupByAllAlgorithms(pathToHashes)
+	default:
+		return fp.groupByAnyAlgorithm(pathToHashes)
+	}
+}
+
+func (fp *FileProcessor) groupByAlgorithm(pathToHashes map[string]MultiHashes, algo string) ([]MultiHashDuplicateGroup, error) {
+	byDigest := make(map[string][]string)
+	for path, hashes := range pathToHashes {
+		digest, ok := hashes.Hashes[algo]
+		if !ok {
+			continue
+		}
+		byDigest[digest] = append(byDigest[digest], path)
+	}
+
+	var groups []MultiHashDuplicateGroup
+	for digest, paths := range byDigest {
+		if len(paths) > 1 {
+			groups = append(groups, MultiHashDuplicateGroup{Algorithm: algo, Digest: digest, Paths: paths})
+		}
+	}
+	return groups, nil
+}
+
+func (fp *FileProcessor) groupByAnyAlgorithm(pathToHashes map[string]MultiHashes) ([]MultiHashDuplicateGroup, error) {
+	var groups []MultiHashDuplicateGroup
+	seenAlgoDigest := make(map[string]bool)
+	for _, hashes := range pathToHashes {
+		for algo, digest := range hashes.Hashes {
+			key := algo + ":" + digest
+			if seenAlgoDigest[key] {
+				continue
+			}
+			seenAlgoDigest[key] = true
+
+			members, err := fp.Rdb.SMembers(fp.Ctx, getMultiHashToPathSetKey(algo, digest)).Result()
+			if err != nil && err != redis.Nil {
+				return nil, fmt.Errorf("error reading multi-hash bucket %s:%s: %w", algo, digest, err)
+			}
+			if len(members) > 1 {
+				groups = append(groups, MultiHashDuplicateGroup{Algorithm: algo, Digest: digest, Paths: members})
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (fp *FileProcessor) groupByAllAlgorithms(pathToHashes map[string]MultiHashes) ([]MultiHashDuplicateGroup, error) {
+	candidates, err := fp.groupByAnyAlgorithm(pathToHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []MultiHashDuplicateGroup
+	for _, candidate := range candidates {
+		byFullDigestSet := make(map[string][]string)
+		for _, path := range candidate.Paths {
+			hashes, ok := pathToHashes[path]
+			if !ok {
+				continue
+			}
+			byFullDigestSet[digestSetKey(hashes)] = append(byFullDigestSet[digestSetKey(hashes)], path)
+		}
+		for _, paths := range byFullDigestSet {
+			if len(paths) > 1 {
+				groups = append(groups, MultiHashDuplicateGroup{Paths: paths})
+			}
+		}
+	}
+	return dedupeMultiHashGroups(groups), nil
+}
+
+// digestSetKey canonicalizes a MultiHashes record into a single string so
+// two files can be compared for exact equality across every algorithm with
+// a map lookup rather than a per-algorithm comparison loop.
+func digestSetKey(hashes MultiHashes) string {
+	algos := make([]string, 0, len(hashes.Hashes))
+	for algo := range hashes.Hashes {
+		algos = append(algos, algo)
+	}
+	sort.Strings(algos)
+
+	var b strings.Builder
+	for _, algo := range algos {
+		b.WriteString(algo)
+		b.WriteByte('=')
+		b.WriteString(hashes.Hashes[algo])
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// dedupeMultiHashGroups drops groups that are exact duplicates of a group
+// already seen, which groupByAllAlgorithms can produce when two files in
+// the same MatchAllAlgorithms group appear as candidates under more than
+// one shared algorithm.
+func dedupeMultiHashGroups(groups []MultiHashDuplicateGroup) []MultiHashDuplicateGroup {
+	seen := make(map[string]bool)
+	var out []MultiHashDuplicateGroup
+	for _, g := range groups {
+		paths := append([]string(nil), g.Paths...)
+		sort.Strings(paths)
+		key := strings.Join(paths, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, g)
+	}
+	return out
+}
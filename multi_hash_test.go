@@ -0,0 +1,125 @@
+// multi_hash_test.go
+
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_ComputeMultiHash(t *testing.T) {
+	_, _, _, fs, fp := setupTestEnvironment(t)
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644))
+
+	digests, err := fp.computeMultiHash("/a.txt", FullFileReadCmd, []string{"md5", "sha1", "sha256", "blake3"})
+	require.NoError(t, err)
+
+	assert.Len(t, digests, 4)
+	for _, algo := range []string{"md5", "sha1", "sha256", "blake3"} {
+		assert.NotEmpty(t, digests[algo], "missing digest for %s", algo)
+	}
+
+	_, err = fp.computeMultiHash("/a.txt", FullFileReadCmd, []string{"not-a-real-algorithm"})
+	assert.Error(t, err)
+}
+
+func TestFileProcessor_ProcessFileMultiHash(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.Algorithms = []string{"md5", "sha256"}
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644))
+	require.NoError(t, fp.ProcessFileMultiHash("/a.txt"))
+
+	hashedKey := generateHash("/a.txt")
+	hashes, err := fp.getMultiHashes(hashedKey)
+	require.NoError(t, err)
+	require.Len(t, hashes.Hashes, 2)
+
+	md5Digest := hashes.Hashes["md5"]
+	require.NotEmpty(t, md5Digest)
+
+	cached, err := rdb.Get(ctx, getFullHashCacheKeyForAlgo(hashedKey, "md5")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, md5Digest, cached)
+
+	members, err := rdb.SMembers(ctx, getMultiHashToPathSetKey("md5", md5Digest)).Result()
+	require.NoError(t, err)
+	assert.Contains(t, members, "/a.txt")
+}
+
+func TestFileProcessor_ProcessFileMultiHashNoopWithoutAlgorithms(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644))
+	require.NoError(t, fp.ProcessFileMultiHash("/a.txt"))
+
+	hashedKey := generateHash("/a.txt")
+	_, err := fp.getMultiHashes(hashedKey)
+	assert.Error(t, err)
+}
+
+func TestFileProcessor_FindMultiHashDuplicatesSpecificAlgorithm(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.Algorithms = []string{"md5", "sha256"}
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("same content"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("same content"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/c.txt", []byte("different"), 0644))
+
+	for _, path := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		seedHashedKeyToPath(t, fp, path)
+		require.NoError(t, fp.ProcessFileMultiHash(path))
+	}
+
+	groups, err := fp.FindMultiHashDuplicates(MatchSpecificAlgorithm, "sha256")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "sha256", groups[0].Algorithm)
+	assert.ElementsMatch(t, []string{"/a.txt", "/b.txt"}, groups[0].Paths)
+}
+
+func TestFileProcessor_FindMultiHashDuplicatesAllAlgorithms(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.Algorithms = []string{"md5", "sha256"}
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("same content"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("same content"), 0644))
+
+	for _, path := range []string{"/a.txt", "/b.txt"} {
+		seedHashedKeyToPath(t, fp, path)
+		require.NoError(t, fp.ProcessFileMultiHash(path))
+	}
+
+	groups, err := fp.FindMultiHashDuplicates(MatchAllAlgorithms, "")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.ElementsMatch(t, []string{"/a.txt", "/b.txt"}, groups[0].Paths)
+}
+
+func TestFileProcessor_FindMultiHashDuplicatesAnyAlgorithm(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+	fp.Algorithms = []string{"md5"}
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("same content"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("same content"), 0644))
+
+	for _, path := range []string{"/a.txt", "/b.txt"} {
+		seedHashedKeyToPath(t, fp, path)
+		require.NoError(t, fp.ProcessFileMultiHash(path))
+	}
+
+	groups, err := fp.FindMultiHashDuplicates(MatchAnyAlgorithm, "")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "md5", groups[0].Algorithm)
+	assert.ElementsMatch(t, []string{"/a.txt", "/b.txt"}, groups[0].Paths)
+}
@@ -0,0 +1,139 @@
+// normalize.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeOptions controls how NormalizeFileName folds a filename before it
+// is compared or hashed. The zero value normalizes NFC only; set the other
+// fields to opt into the more aggressive folding used by similarity and
+// dedup matching.
+type NormalizeOptions struct {
+	CaseFold       bool     // lower-case the result
+	StripDiacritic bool     // remove combining accents (café -> cafe)
+	CollapseSeps   bool     // collapse runs of "_", "-", ".", whitespace into one delimiter
+	NoiseTokens    []string // tokens stripped after collapsing, e.g. release tags
+}
+
+// DefaultNormalizeOptions matches the folding CloseFileFinder applies when
+// comparing filenames: case-insensitive, accent-insensitive, with common
+// release-group/resolution/language noise removed.
+func DefaultNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{
+		CaseFold:       true,
+		StripDiacritic: true,
+		CollapseSeps:   true,
+		NoiseTokens:    defaultNoiseTokens,
+	}
+}
+
+// defaultNoiseTokens lists common resolution markers, release-group tags and
+// language codes that carry no signal for filename similarity.
+var defaultNoiseTokens = []string{
+	"1080p", "720p", "2160p", "480p", "4k",
+	"x264", "x265", "hevc", "h264", "h265",
+	"web", "webrip", "bluray", "brrip", "dvdrip",
+	"eng", "chs", "cht", "jpn",
+}
+
+var separatorPattern = regexp.MustCompile(`[_\-.\s]+`)
+
+// newDiacriticTransformer builds a fresh transform.Chain per call rather
+// than sharing one package-level instance: transform.Transformer keeps
+// internal state across Transform calls and is not safe for concurrent use,
+// and NormalizeFileName is called concurrently from CloseFileFinder's
+// worker pool (see close_files.go).
+func newDiacriticTransformer() transform.Transformer {
+	return transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+}
+
+// NormalizeFileName folds name according to opts so that filenames which
+// differ only in accents, case, separator style, or embedded release/quality
+// noise compare as identical (or near-identical after a Levenshtein pass).
+// Unicode input is always brought to NFC form first, mirroring the approach
+// Hugo's MakePath uses for URL-safe slugs.
+func NormalizeFileName(name string, opts NormalizeOptions) string {
+	result := norm.NFC.String(name)
+
+	if opts.StripDiacritic {
+		if stripped, _, err := transform.String(newDiacriticTransformer(), result); err == nil {
+			result = stripped
+		}
+	}
+
+	if opts.CaseFold {
+		result = strings.ToLower(result)
+	}
+
+	if opts.CollapseSeps {
+		result = separatorPattern.ReplaceAllString(result, "_")
+		result = strings.Trim(result, "_")
+	}
+
+	if len(opts.NoiseTokens) > 0 {
+		result = stripNoiseTokens(result, opts.NoiseTokens)
+	}
+
+	return result
+}
+
+// loadNoiseTokens reads one noise token per line from filename (the same
+// config-file convention loadExcludePatterns uses) and returns them for use
+// in NormalizeOptions.NoiseTokens. A missing file is not an error: it
+// returns a nil slice, so no noise tokens are stripped.
+func loadNoiseTokens(filename string, fs afero.Fs) ([]string, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error opening noise tokens file: %w", err)
+	}
+	defer file.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token == "" || strings.HasPrefix(token, "#") {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading noise tokens file: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// stripNoiseTokens removes "_"-delimited tokens (case-insensitive) that
+// appear in noise, then re-collapses any separators the removal exposed.
+func stripNoiseTokens(name string, noise []string) string {
+	noiseSet := make(map[string]struct{}, len(noise))
+	for _, n := range noise {
+		noiseSet[strings.ToLower(n)] = struct{}{}
+	}
+
+	parts := strings.Split(name, "_")
+	kept := parts[:0]
+	for _, part := range parts {
+		if _, isNoise := noiseSet[strings.ToLower(part)]; isNoise {
+			continue
+		}
+		kept = append(kept, part)
+	}
+
+	return strings.Trim(strings.Join(kept, "_"), "_")
+}
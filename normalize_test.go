@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFileNameAccentsAndCJK(t *testing.T) {
+	opts := DefaultNormalizeOptions()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"accent", "café", "cafe"},
+		{"separators", "test_文件_1", "test_文件_1"},
+		{"case", "Test测试文件1", "test测试文件1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeFileName(tc.input, opts))
+		})
+	}
+}
+
+func TestNormalizeFileNameStripsNoiseTokens(t *testing.T) {
+	opts := DefaultNormalizeOptions()
+	result := NormalizeFileName("Movie_Title_1080p_x264", opts)
+	assert.Equal(t, "movie_title", result)
+}
+
+func TestNormalizeFileNameEmptyOptionsIsNFCOnly(t *testing.T) {
+	result := NormalizeFileName("Test_File", NormalizeOptions{})
+	assert.Equal(t, "Test_File", result)
+}
+
+func TestCalculateSimilarityAcrossNormalizationVariants(t *testing.T) {
+	score := calculateSimilarity("Test测试文件1.txt", "test_文件_1.txt")
+	assert.Greater(t, score, 0.7)
+}
+
+func TestLoadNoiseTokens(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/noise.txt", []byte("1080p\n# comment\nx264\n\n"), 0644))
+
+	tokens, err := loadNoiseTokens("/noise.txt", fs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1080p", "x264"}, tokens)
+}
@@ -0,0 +1,77 @@
+// patterns.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// gitignoreToRegexp translates a single .gitignore-style glob pattern into a
+// compiled regular expression. "**" matches across path separators, "*" and
+// "?" are confined to a single path segment, a leading "/" anchors the
+// pattern to the root, and a leading "!" marks the pattern as a negation
+// (the caller decides what to do with the returned negate flag).
+func gitignoreToRegexp(pattern string) (*regexp.Regexp, bool, error) {
+	negate := strings.HasPrefix(pattern, "!")
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var sb strings.Builder
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("(?:^|/)")
+	}
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '/':
+			sb.WriteString("/")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, negate, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+	}
+	return re, negate, nil
+}
+
+// compileIncludePatterns compiles a list of .gitignore-style glob patterns
+// for use as IncludePatterns, splitting them into positive matches and
+// negations (patterns prefixed with "!").
+func compileIncludePatterns(patterns []string) (include []*regexp.Regexp, exclude []*regexp.Regexp, err error) {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, negate, err := gitignoreToRegexp(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		if negate {
+			exclude = append(exclude, re)
+		} else {
+			include = append(include, re)
+		}
+	}
+	return include, exclude, nil
+}
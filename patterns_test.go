@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitignoreToRegexp(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"*.mp4", "movie.mp4", true},
+		{"*.mp4", "dir/movie.mp4", true},
+		{"*.mp4", "movie.mkv", false},
+		{"**/cache/*", "a/b/cache/file", true},
+		{"/root.txt", "root.txt", true},
+		{"/root.txt", "dir/root.txt", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+"_"+tc.path, func(t *testing.T) {
+			re, negate, err := gitignoreToRegexp(tc.pattern)
+			require.NoError(t, err)
+			assert.False(t, negate)
+			assert.Equal(t, tc.matches, re.MatchString(tc.path))
+		})
+	}
+}
+
+func TestGitignoreToRegexpNegation(t *testing.T) {
+	_, negate, err := gitignoreToRegexp("!*.iso")
+	require.NoError(t, err)
+	assert.True(t, negate)
+}
+
+func TestFileProcessorShouldExcludeWithIncludePatterns(t *testing.T) {
+	fp := CreateFileProcessor(nil, context.Background(), nil)
+
+	require.NoError(t, fp.AddIncludePattern("*.mp4"))
+	require.NoError(t, fp.AddIncludePattern("*.iso"))
+	require.NoError(t, fp.AddIncludePattern("!private_*.mp4"))
+
+	testCases := []struct {
+		path     string
+		excluded bool
+	}{
+		{"movie.mp4", false},
+		{"image.iso", false},
+		{"notes.txt", true},
+		{"private_movie.mp4", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.excluded, fp.ShouldExclude(tc.path))
+		})
+	}
+}
+
+func TestFileProcessorEmptyIncludePatternsMatchEverything(t *testing.T) {
+	fp := CreateFileProcessor(nil, context.Background(), nil)
+	assert.False(t, fp.ShouldExclude("anything.txt"))
+}
+
+func TestFileProcessorIncludeAppliedBeforeExclude(t *testing.T) {
+	excludeRules, err := compileExcludePatterns([]string{`re:.*\.tmp$`})
+	require.NoError(t, err)
+
+	fp := CreateFileProcessor(nil, context.Background(), excludeRules)
+	require.NoError(t, fp.AddIncludePattern("*.mp4"))
+
+	assert.True(t, fp.ShouldExclude("movie.tmp"), "excluded even though it would also fail include")
+	assert.False(t, fp.ShouldExclude("movie.mp4"))
+	assert.True(t, fp.ShouldExclude("notes.txt"), "fails include, never reaches exclude check")
+}
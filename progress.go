@@ -0,0 +1,228 @@
+// progress.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+)
+
+// Progress phases reported in ProgressReport.Phase.
+const (
+	PhaseWalking = "walking"
+	PhaseHashing = "hashing"
+	PhaseDone    = "done"
+)
+
+// ProgressReport is one update ProcessTree/ProcessFileCancelable emits on a
+// caller-supplied channel as a scan runs: BytesHashed/TotalBytes track the
+// file currently being hashed, FilesDone/FilesTotal track the scan as a
+// whole. Unlike Event (see events.go), which records discrete steps for
+// external consumption, ProgressReport is meant to drive an in-process
+// progress bar, so it's delivered on a typed channel rather than through an
+// EventSink.
+type ProgressReport struct {
+	Path        string
+	BytesHashed int64
+	TotalBytes  int64
+	FilesDone   int
+	FilesTotal  int
+	Phase       string
+}
+
+const keyPrefixScanState = "scanState:"
+
+func getScanStateKey(hashedKey string) string {
+	return keyPrefixScanState + hashedKey
+}
+
+// isFileScanned reports whether fullPath's scanState:<hashedKey> marker is
+// already set, letting ProcessTree resume a scan a prior run was cancelled
+// partway through without re-hashing files it already finished.
+func (fp *FileProcessor) isFileScanned(fullPath string) (bool, error) {
+	hashedKey := fp.generateHashFunc(fullPath)
+	_, err := fp.Rdb.Get(fp.Ctx, getScanStateKey(hashedKey)).Result()
+	if err == nil {
+		return true, nil
+	}
+	if err == redis.Nil {
+		return false, nil
+	}
+	return false, err
+}
+
+// markFileScanned sets fullPath's scanState:<hashedKey> marker so a later
+// resumed run skips it.
+func (fp *FileProcessor) markFileScanned(fullPath string) error {
+	hashedKey := fp.generateHashFunc(fullPath)
+	return fp.Rdb.Set(fp.Ctx, getScanStateKey(hashedKey), "done", 0).Err()
+}
+
+// reportProgress sends report on progress if progress is non-nil; a nil
+// channel (the common case when a caller doesn't care about progress)
+// disables reporting entirely rather than blocking the scan on a channel no
+// one reads.
+func reportProgress(progress chan<- ProgressReport, report ProgressReport) {
+	if progress == nil {
+		return
+	}
+	select {
+	case progress <- report:
+	default:
+	}
+}
+
+// hashFileCancelable computes fullPath's full-file hash the same way
+// calculateFileHash's FullFileReadCmd tier does, except it reads in fixed
+// chunks and checks ctx.Done() between each one, so a SIGINT or API cancel
+// aborts the read promptly instead of running to completion. On
+// cancellation it returns ctx.Err() without having written anything to
+// Redis, so a cancelled hash never produces a hashedKeyToFullHash: entry for
+// callers to mistake for a completed one.
+func (fp *FileProcessor) hashFileCancelable(ctx context.Context, fullPath string, totalBytes int64, progress chan<- ProgressReport) (string, error) {
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+
+	hasher := fp.hasherFor(FullFileReadCmd)
+	h := hasher.New()
+	buf := make([]byte, 32*1024)
+	var bytesHashed int64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		n, rerr := f.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			bytesHashed += int64(n)
+			reportProgress(progress, ProgressReport{
+				Path:        fullPath,
+				BytesHashed: bytesHashed,
+				TotalBytes:  totalBytes,
+				Phase:       PhaseHashing,
+			})
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return "", fmt.Errorf("error reading %s: %w", fullPath, rerr)
+		}
+	}
+
+	return hashWithAlgoPrefix(hasher.Name(), h.Sum(nil)), nil
+}
+
+// ProcessFileCancelable hashes fullPath the same way ProcessFile's full-hash
+// tier does, but honors ctx.Done() between reads (see hashFileCancelable)
+// and consults/updates scanState:<hashedKey> so a scan interrupted partway
+// through can resume without re-hashing files it already finished. Cancelling
+// ctx mid-hash returns ctx.Err() and leaves no hashedKeyToFullHash: entry
+// behind.
+func (fp *FileProcessor) ProcessFileCancelable(ctx context.Context, rootDir, relativePath string, progress chan<- ProgressReport) error {
+	fullPath := filepath.Join(rootDir, relativePath)
+
+	scanned, err := fp.isFileScanned(fullPath)
+	if err != nil {
+		return fmt.Errorf("error checking scan state for %s: %w", fullPath, err)
+	}
+	if scanned {
+		return nil
+	}
+
+	info, err := fp.fs.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("error getting file info: %w", err)
+	}
+
+	fullHash, err := fp.hashFileCancelable(ctx, fullPath, info.Size(), progress)
+	if err != nil {
+		return err
+	}
+
+	hashedKey := fp.generateHashFunc(fullPath)
+	if err := fp.Rdb.Set(fp.Ctx, getFullHashCacheKey(hashedKey), fullHash, 0).Err(); err != nil {
+		return fmt.Errorf("error caching full hash for %s: %w", fullPath, err)
+	}
+
+	if err := fp.markFileScanned(fullPath); err != nil {
+		log.Printf("Warning: failed to mark %s as scanned: %v", fullPath, err)
+	}
+
+	return nil
+}
+
+// collectTreeFiles walks rootDir and returns every regular file's path
+// relative to rootDir.
+func (fp *FileProcessor) collectTreeFiles(rootDir string) ([]string, error) {
+	var relativePaths []string
+	err := afero.Walk(fp.fs, rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relativePath, relErr := filepath.Rel(rootDir, path)
+		if relErr != nil {
+			return relErr
+		}
+		relativePaths = append(relativePaths, relativePath)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking %s: %w", rootDir, err)
+	}
+	return relativePaths, nil
+}
+
+// ProcessTree walks rootDir and runs ProcessFileCancelable over every
+// regular file it finds, emitting ProgressReport updates on progress (nil
+// disables reporting) and returning ctx.Err() as soon as ctx is cancelled,
+// whether that happens between files or mid-hash inside one. Because
+// ProcessFileCancelable consults scanState before hashing, re-running
+// ProcessTree with the same rootDir after a cancellation resumes from
+// wherever it left off rather than re-hashing everything.
+func (fp *FileProcessor) ProcessTree(ctx context.Context, rootDir string, progress chan<- ProgressReport) ([]string, error) {
+	relativePaths, err := fp.collectTreeFiles(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	filesTotal := len(relativePaths)
+	reportProgress(progress, ProgressReport{Phase: PhaseWalking, FilesTotal: filesTotal})
+
+	for i, relativePath := range relativePaths {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if err := fp.ProcessFileCancelable(ctx, rootDir, relativePath, progress); err != nil {
+			return nil, err
+		}
+
+		reportProgress(progress, ProgressReport{
+			Path:       filepath.Join(rootDir, relativePath),
+			FilesDone:  i + 1,
+			FilesTotal: filesTotal,
+			Phase:      PhaseDone,
+		})
+	}
+
+	return relativePaths, nil
+}
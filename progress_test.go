@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_ProcessFileCancelable_CancelMidHashLeavesNoFullHash(t *testing.T) {
+	mr, rdb, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/big.bin", make([]byte, 1024*1024), 0644))
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err := fp.ProcessFileCancelable(cancelCtx, "/", "big.bin", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	hashedKey := generateHash("/big.bin")
+	_, err = rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestFileProcessor_ProcessFileCancelable_ResumeSkipsScannedFiles(t *testing.T) {
+	mr, _, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello"), 0644))
+	require.NoError(t, fp.ProcessFileCancelable(ctx, "/", "a.txt", nil))
+
+	hashedKey := generateHash("/a.txt")
+	firstHash, err := fp.Rdb.Get(fp.Ctx, getFullHashCacheKey(hashedKey)).Result()
+	require.NoError(t, err)
+
+	// Overwrite the cached hash to prove a resumed run doesn't recompute it.
+	require.NoError(t, fp.Rdb.Set(fp.Ctx, getFullHashCacheKey(hashedKey), "stale", 0).Err())
+
+	require.NoError(t, fp.ProcessFileCancelable(ctx, "/", "a.txt", nil))
+
+	hashAfterResume, err := fp.Rdb.Get(fp.Ctx, getFullHashCacheKey(hashedKey)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "stale", hashAfterResume)
+	assert.NotEqual(t, firstHash, "stale")
+}
+
+func TestFileProcessor_ProcessTree_ProgressTotalsSumAcrossFiles(t *testing.T) {
+	mr, _, ctx, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/root/a.txt", []byte("aaaa"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/b.txt", []byte("bbbbbbbb"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/root/c.txt", []byte("cc"), 0644))
+
+	progress := make(chan ProgressReport, 64)
+	var wg sync.WaitGroup
+	var totalBytesHashed int64
+	var filesDone int
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for report := range progress {
+			if report.Phase == PhaseHashing {
+				totalBytesHashed += report.BytesHashed
+			}
+			if report.Phase == PhaseDone {
+				filesDone = report.FilesDone
+			}
+		}
+	}()
+
+	relativePaths, err := fp.ProcessTree(ctx, "/root", progress)
+	close(progress)
+	wg.Wait()
+
+	require.NoError(t, err)
+	assert.Len(t, relativePaths, 3)
+	assert.Equal(t, 3, filesDone)
+	assert.Greater(t, totalBytesHashed, int64(0))
+}
@@ -0,0 +1,191 @@
+// pruner.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PrunePolicy configures FileProcessor.Prune, following the eviction model
+// Hugo's filecache uses: an age-based TTL plus size-based caps, any
+// combination of which may be enabled. A zero value in a given field
+// disables that policy.
+type PrunePolicy struct {
+	// TTL evicts entries whose FileInfo.ModTime AND lastSeen (the unix
+	// timestamp ProcessFile refreshes on every visit) are both older than
+	// this duration. Zero disables TTL pruning.
+	TTL time.Duration
+
+	// MaxEntries keeps only the MaxEntries largest-by-size entries,
+	// evicting the rest. Zero disables this policy.
+	MaxEntries int
+
+	// MaxTotalBytes evicts smallest-first until the remaining entries'
+	// FileInfo.Size sums to at most this many bytes. Zero disables this
+	// policy.
+	MaxTotalBytes int64
+}
+
+// PruneStats reports how many entries each policy evicted. Policies run in
+// the order TTL, MaxEntries, MaxTotalBytes, each operating on the survivors
+// of the previous one, so an entry evicted by TTL is never double-counted
+// against MaxEntries or MaxTotalBytes.
+type PruneStats struct {
+	TTLEvicted           int
+	MaxEntriesEvicted    int
+	MaxTotalBytesEvicted int
+}
+
+// pruneEntry is one "fileInfo:*" record plus the derived keys Prune needs in
+// order to evict it cleanly.
+type pruneEntry struct {
+	hashedKey string
+	path      string
+	info      FileInfo
+	lastSeen  time.Time
+	fileHash  string
+	fullHash  string
+}
+
+// Prune evicts metadata according to policy and reports how many entries
+// each stage removed. It scans every "fileInfo:*" record rather than relying
+// on CleanUpOldRecords' "does the file still exist" check, so it can also
+// cap the cache's age and size even when the backing files are still there.
+func (fp *FileProcessor) Prune(ctx context.Context, policy PrunePolicy) (PruneStats, error) {
+	entries, err := fp.collectPruneEntries(ctx)
+	if err != nil {
+		return PruneStats{}, err
+	}
+
+	var stats PruneStats
+
+	if policy.TTL > 0 {
+		cutoff := time.Now().Add(-policy.TTL)
+		var survivors []pruneEntry
+		for _, e := range entries {
+			if e.info.ModTime.Before(cutoff) && e.lastSeen.Before(cutoff) {
+				if err := fp.evictPruneEntry(ctx, e); err != nil {
+					log.Printf("Error evicting %s during TTL prune: %v", e.path, err)
+					continue
+				}
+				stats.TTLEvicted++
+				continue
+			}
+			survivors = append(survivors, e)
+		}
+		entries = survivors
+	}
+
+	if policy.MaxEntries > 0 && len(entries) > policy.MaxEntries {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].info.Size > entries[j].info.Size })
+		keep, evict := entries[:policy.MaxEntries], entries[policy.MaxEntries:]
+		for _, e := range evict {
+			if err := fp.evictPruneEntry(ctx, e); err != nil {
+				log.Printf("Error evicting %s during MaxEntries prune: %v", e.path, err)
+				continue
+			}
+			stats.MaxEntriesEvicted++
+		}
+		entries = keep
+	}
+
+	if policy.MaxTotalBytes > 0 {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].info.Size < entries[j].info.Size })
+		var total int64
+		for _, e := range entries {
+			total += e.info.Size
+		}
+		i := 0
+		for total > policy.MaxTotalBytes && i < len(entries) {
+			e := entries[i]
+			if err := fp.evictPruneEntry(ctx, e); err != nil {
+				log.Printf("Error evicting %s during MaxTotalBytes prune: %v", e.path, err)
+				i++
+				continue
+			}
+			total -= e.info.Size
+			stats.MaxTotalBytesEvicted++
+			i++
+		}
+	}
+
+	return stats, nil
+}
+
+// collectPruneEntries loads every "fileInfo:*" record along with the derived
+// state Prune's policies and evictPruneEntry need.
+func (fp *FileProcessor) collectPruneEntries(ctx context.Context) ([]pruneEntry, error) {
+	var entries []pruneEntry
+
+	iter := fp.Rdb.Scan(ctx, 0, keyPrefixFileInfo+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		hashedKey := iter.Val()[len(keyPrefixFileInfo):]
+
+		path, err := fp.Rdb.Get(ctx, getHashedKeyToPathKey(hashedKey)).Result()
+		if err != nil {
+			log.Printf("Error getting path for key %s: %v", hashedKey, err)
+			continue
+		}
+
+		info, err := fp.getFileInfoFromRedis(hashedKey)
+		if err != nil {
+			log.Printf("Error getting file info for key %s: %v", hashedKey, err)
+			continue
+		}
+
+		lastSeen := info.ModTime
+		if raw, err := fp.Rdb.Get(ctx, getLastSeenKey(hashedKey)).Result(); err == nil {
+			if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				lastSeen = time.Unix(unix, 0)
+			}
+		}
+
+		fileHash, _ := fp.Rdb.Get(ctx, getHashCacheKey(hashedKey)).Result()
+		fullHash, _ := fp.Rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+
+		entries = append(entries, pruneEntry{
+			hashedKey: hashedKey,
+			path:      path,
+			info:      info,
+			lastSeen:  lastSeen,
+			fileHash:  fileHash,
+			fullHash:  fullHash,
+		})
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning fileInfo keys: %w", err)
+	}
+
+	return entries, nil
+}
+
+// evictPruneEntry deletes e and every key derived from it across all seven
+// Redis key families: fileInfo, hashedKeyToPath, pathToHashedKey,
+// hashedKeyToFileHash, hashedKeyToFullHash, lastSeen, and this entry's
+// membership in fileHashToPathSet/duplicateFiles.
+func (fp *FileProcessor) evictPruneEntry(ctx context.Context, e pruneEntry) error {
+	pipe := fp.Rdb.Pipeline()
+	pipe.Del(ctx, getFileInfoKey(e.hashedKey))
+	pipe.Del(ctx, getHashedKeyToPathKey(e.hashedKey))
+	pipe.Del(ctx, getPathToHashedKeyKey(e.path))
+	pipe.Del(ctx, getHashCacheKey(e.hashedKey))
+	pipe.Del(ctx, getFullHashCacheKey(e.hashedKey))
+	pipe.Del(ctx, getLastSeenKey(e.hashedKey))
+	if e.fileHash != "" {
+		pipe.SRem(ctx, getFileHashKey(e.fileHash), e.path)
+	}
+	if e.fullHash != "" {
+		pipe.ZRem(ctx, getDuplicateFilesKey(e.fullHash), e.path)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error evicting entry for %s: %w", e.path, err)
+	}
+	fp.emit(Event{Type: EventPruneEvicted, Path: e.path, Size: e.info.Size})
+	return nil
+}
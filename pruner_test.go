@@ -0,0 +1,180 @@
+// pruner_test.go
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedPruneEntry writes all the Redis state a real ProcessFile/
+// saveFileInfoToRedis call would have produced for one file, including
+// derived fileHash/fullHash membership and a lastSeen timestamp, so Prune's
+// policies and key cleanup can be exercised end to end.
+func seedPruneEntry(t *testing.T, fp *FileProcessor, path string, size int64, modTime, lastSeen time.Time, fileHash, fullHash string) {
+	t.Helper()
+	ctx := fp.Ctx
+
+	info := FileInfo{Size: size, ModTime: modTime, Path: path}
+	err := saveFileInfoToRedis(fp.Rdb, ctx, path, info, fileHash, fullHash, true)
+	require.NoError(t, err)
+
+	hashedKey := generateHash(path)
+	err = fp.Rdb.Set(ctx, getLastSeenKey(hashedKey), lastSeen.Unix(), 0).Err()
+	require.NoError(t, err)
+
+	if fullHash != "" {
+		err = SaveDuplicateFileInfoToRedis(fp.Rdb, ctx, fullHash, info)
+		require.NoError(t, err)
+	}
+}
+
+func assertEntryGone(t *testing.T, fp *FileProcessor, path, fileHash, fullHash string) {
+	t.Helper()
+	ctx := fp.Ctx
+	hashedKey := generateHash(path)
+
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getFileInfoKey(hashedKey))))
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getHashedKeyToPathKey(hashedKey))))
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getPathToHashedKeyKey(path))))
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getHashCacheKey(hashedKey))))
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getFullHashCacheKey(hashedKey))))
+	assert.Equal(t, redis.Nil, getErr(fp.Rdb.Get(ctx, getLastSeenKey(hashedKey))))
+
+	if fileHash != "" {
+		isMember, err := fp.Rdb.SIsMember(ctx, getFileHashKey(fileHash), path).Result()
+		require.NoError(t, err)
+		assert.False(t, isMember)
+	}
+	if fullHash != "" {
+		score, err := fp.Rdb.ZScore(ctx, getDuplicateFilesKey(fullHash), path).Result()
+		assert.Equal(t, redis.Nil, err)
+		assert.Zero(t, score)
+	}
+}
+
+func assertEntrySurvives(t *testing.T, fp *FileProcessor, path, fileHash, fullHash string) {
+	t.Helper()
+	ctx := fp.Ctx
+	hashedKey := generateHash(path)
+
+	assert.True(t, keyExists(t, fp, getFileInfoKey(hashedKey)))
+	assert.True(t, keyExists(t, fp, getHashedKeyToPathKey(hashedKey)))
+	assert.True(t, keyExists(t, fp, getPathToHashedKeyKey(path)))
+
+	if fileHash != "" {
+		isMember, err := fp.Rdb.SIsMember(ctx, getFileHashKey(fileHash), path).Result()
+		require.NoError(t, err)
+		assert.True(t, isMember)
+	}
+	if fullHash != "" {
+		_, err := fp.Rdb.ZScore(ctx, getDuplicateFilesKey(fullHash), path).Result()
+		require.NoError(t, err)
+	}
+}
+
+func keyExists(t *testing.T, fp *FileProcessor, key string) bool {
+	t.Helper()
+	n, err := fp.Rdb.Exists(fp.Ctx, key).Result()
+	require.NoError(t, err)
+	return n == 1
+}
+
+func getErr(cmd interface{ Err() error }) error {
+	return cmd.Err()
+}
+
+func TestPruneByTTL(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/media/old.txt", 100, now.Add(-48*time.Hour), now.Add(-48*time.Hour), "oldhash", "")
+	seedPruneEntry(t, fp, "/media/fresh.txt", 100, now, now, "freshhash", "")
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{TTL: 24 * time.Hour})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, stats.TTLEvicted)
+	assertEntryGone(t, fp, "/media/old.txt", "oldhash", "")
+	assertEntrySurvives(t, fp, "/media/fresh.txt", "freshhash", "")
+}
+
+func TestPruneByTTLKeepsRecentlySeenEntry(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	// Old ModTime, but revisited recently - should survive.
+	seedPruneEntry(t, fp, "/media/revisited.txt", 100, now.Add(-48*time.Hour), now, "hash", "")
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{TTL: 24 * time.Hour})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, stats.TTLEvicted)
+	assertEntrySurvives(t, fp, "/media/revisited.txt", "hash", "")
+}
+
+func TestPruneByMaxEntries(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/media/big.txt", 300, now, now, "bighash", "")
+	seedPruneEntry(t, fp, "/media/medium.txt", 200, now, now, "mediumhash", "")
+	seedPruneEntry(t, fp, "/media/small.txt", 100, now, now, "smallhash", "")
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{MaxEntries: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, stats.MaxEntriesEvicted)
+	assertEntryGone(t, fp, "/media/small.txt", "smallhash", "")
+	assertEntrySurvives(t, fp, "/media/big.txt", "bighash", "")
+	assertEntrySurvives(t, fp, "/media/medium.txt", "mediumhash", "")
+}
+
+func TestPruneByMaxTotalBytes(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/media/big.txt", 300, now, now, "bighash", "fullbig")
+	seedPruneEntry(t, fp, "/media/medium.txt", 200, now, now, "mediumhash", "")
+	seedPruneEntry(t, fp, "/media/small.txt", 100, now, now, "smallhash", "")
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{MaxTotalBytes: 400})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, stats.MaxTotalBytesEvicted)
+	assertEntryGone(t, fp, "/media/small.txt", "smallhash", "")
+	assertEntryGone(t, fp, "/media/medium.txt", "mediumhash", "")
+	assertEntrySurvives(t, fp, "/media/big.txt", "bighash", "fullbig")
+}
+
+func TestPruneCombinesPolicies(t *testing.T) {
+	mr, _, _, _, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	now := time.Now()
+	seedPruneEntry(t, fp, "/media/expired.txt", 500, now.Add(-72*time.Hour), now.Add(-72*time.Hour), "expiredhash", "")
+	seedPruneEntry(t, fp, "/media/big.txt", 300, now, now, "bighash", "")
+	seedPruneEntry(t, fp, "/media/medium.txt", 200, now, now, "mediumhash", "")
+	seedPruneEntry(t, fp, "/media/small.txt", 100, now, now, "smallhash", "")
+
+	stats, err := fp.Prune(fp.Ctx, PrunePolicy{
+		TTL:        24 * time.Hour,
+		MaxEntries: 2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, stats.TTLEvicted)
+	assert.Equal(t, 1, stats.MaxEntriesEvicted)
+	assertEntryGone(t, fp, "/media/expired.txt", "expiredhash", "")
+	assertEntryGone(t, fp, "/media/small.txt", "smallhash", "")
+	assertEntrySurvives(t, fp, "/media/big.txt", "bighash", "")
+	assertEntrySurvives(t, fp, "/media/medium.txt", "mediumhash", "")
+}
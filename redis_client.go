@@ -25,6 +25,7 @@ const (
 	keyPrefixHashCache       = "hashedKeyToFileHash:"
 	keyPrefixFullHashCache   = "hashedKeyToFullHash:"
 	keyPrefixCalculating     = "calculating:"
+	keyPrefixLastSeen        = "lastSeen:"
 )
 
 // Generate a SHA-256 hash for the given string
@@ -66,6 +67,24 @@ func getCalculatingKey(path string, limit int64) string {
 	return fmt.Sprintf("%s%s:%d", keyPrefixCalculating, path, limit)
 }
 
+func getLastSeenKey(hashedKey string) string {
+	return keyPrefixLastSeen + hashedKey
+}
+
+// getHashCacheKeyForAlgo and getFullHashCacheKeyForAlgo namespace a cached
+// digest by the algorithm that produced it (e.g.
+// "hashedKeyToFullHash:sha256:<hashedKey>"), so MultiHasher (see
+// multi_hash.go) can store several algorithms' hashes for the same file
+// side by side instead of overwriting the single hashedKeyToFileHash/
+// hashedKeyToFullHash entry calculateFileHash uses.
+func getHashCacheKeyForAlgo(hashedKey, algo string) string {
+	return keyPrefixHashCache + algo + ":" + hashedKey
+}
+
+func getFullHashCacheKeyForAlgo(hashedKey, algo string) string {
+	return keyPrefixFullHashCache + algo + ":" + hashedKey
+}
+
 func saveFileInfoToRedis(rdb *redis.Client, ctx context.Context, fullPath string, info FileInfo, fileHash, fullHash string, calculateHashes bool) error {
 	hashedKey := generateHash(fullPath)
 
@@ -118,7 +137,29 @@ func SaveDuplicateFileInfoToRedis(rdb *redis.Client, ctx context.Context, fullHa
 	return nil
 }
 
+// SaveDuplicateFileInfoToRedis records info as a member of fullHash's
+// duplicate set, same as the package-level function of the same name, and
+// additionally emits a duplicate_group Event through fp.EventSink (see
+// events.go) so callers that go through FileProcessor get progress output
+// without having to watch Redis directly.
+func (fp *FileProcessor) SaveDuplicateFileInfoToRedis(fullHash string, info FileInfo) error {
+	if err := SaveDuplicateFileInfoToRedis(fp.Rdb, fp.Ctx, fullHash, info); err != nil {
+		fp.emit(Event{Type: EventError, Path: info.Path, Error: err.Error()})
+		return err
+	}
+	fp.emit(Event{Type: EventDuplicateGroup, GroupID: fullHash, Path: info.Path, Kind: "exact"})
+	return nil
+}
+
 func CleanUpOldRecords(rdb *redis.Client, ctx context.Context) error {
+	return CleanUpOldRecordsWithInvalidate(rdb, ctx, nil)
+}
+
+// CleanUpOldRecordsWithInvalidate behaves like CleanUpOldRecords, additionally
+// calling invalidate (if non-nil) with every path whose records it deletes,
+// so a caller holding an in-process LRU cache (see lru_cache.go) can evict
+// the now-stale entries instead of waiting for their natural LRU eviction.
+func CleanUpOldRecordsWithInvalidate(rdb *redis.Client, ctx context.Context, invalidate func(path string)) error {
 	log.Println("Starting to clean up old records")
 	iter := rdb.Scan(ctx, 0, "pathToHashedKey:*", 0).Iterator()
 	for iter.Next(ctx) {
@@ -129,6 +170,10 @@ func CleanUpOldRecords(rdb *redis.Client, ctx context.Context) error {
 			err := cleanUpRecordsByFilePath(rdb, ctx, filePath)
 			if err != nil && err != redis.Nil {
 				log.Printf("Error cleaning up records for file %s: %s\n", filePath, err)
+				continue
+			}
+			if invalidate != nil {
+				invalidate(filePath)
 			}
 		}
 	}
@@ -141,6 +186,25 @@ func CleanUpOldRecords(rdb *redis.Client, ctx context.Context) error {
 	return nil
 }
 
+// CleanUpOldRecords runs CleanUpOldRecordsWithInvalidate against fp.Rdb,
+// additionally evicting each removed path's entries from fp.HashedKeyLRU/
+// fp.FileInfoLRU. hashedKey is recomputed locally via generateHash rather
+// than read back from Redis, since by the time invalidate runs
+// cleanUpRecordsByFilePath has already deleted pathToHashedKey:<path>.
+func (fp *FileProcessor) CleanUpOldRecords() error {
+	return CleanUpOldRecordsWithInvalidate(fp.Rdb, fp.Ctx, func(path string) {
+		hashedKey := generateHash(path)
+		if fp.HashedKeyLRU != nil {
+			fp.HashedKeyLRU.Remove(getPathToHashedKeyKey(path))
+			fp.HashedKeyLRU.Remove(getHashCacheKey(hashedKey))
+			fp.HashedKeyLRU.Remove(getFullHashCacheKey(hashedKey))
+		}
+		if fp.FileInfoLRU != nil {
+			fp.FileInfoLRU.Remove(getFileInfoKey(hashedKey))
+		}
+	})
+}
+
 func cleanUpRecordsByFilePath(rdb *redis.Client, ctx context.Context, fullPath string) error {
 	hashedKey := generateHash(fullPath)
 
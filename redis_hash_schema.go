@@ -0,0 +1,271 @@
+// redis_hash_schema.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	keyPrefixFileRecord = "file:"
+	schemaVersionKey    = "schema:version"
+
+	// fileRecordSchemaVersion is recorded at schemaVersionKey once
+	// MigrateToHashSchema has run, so CheckSchemaVersion can refuse to let
+	// an older binary - built before file:<hashedKey> existed - read a
+	// migrated database through the legacy fileInfo:/hashedKeyToFileHash:
+	// keys it would otherwise find silently empty.
+	fileRecordSchemaVersion = "2"
+)
+
+func getFileRecordKey(hashedKey string) string {
+	return keyPrefixFileRecord + hashedKey
+}
+
+// FileRecord is FileInfo plus its dedup hashes and checksums, as stored in
+// the consolidated file:<hashedKey> Redis hash (see saveFileRecord). It
+// replaces five or six separate keys - fileInfo:, hashedKeyToPath:,
+// pathToHashedKey:, hashedKeyToFileHash:, hashedKeyToFullHash:, checksums: -
+// with the fields of a single HSET, so reading or deleting everything known
+// about one file costs one round trip instead of several.
+type FileRecord struct {
+	Path        string
+	Size        int64
+	ModTime     time.Time
+	PartialHash string
+	FullHash    string
+	Checksums   Checksums
+}
+
+// saveFileRecord HSETs info, fileHash (the partial/head hash), fullHash, and
+// checksums for fullPath under file:<hashedKey>, where hashedKey is always
+// generateHash(fullPath). Unlike saveFileInfoToRedis, there's no
+// hashedKeyToPath/pathToHashedKey reverse mapping to maintain: the path is
+// just another field of the same hash, and any caller holding fullPath can
+// already derive hashedKey directly. fileHash, fullHash, and every
+// Checksums field are optional; blank ones are simply omitted.
+func saveFileRecord(rdb *redis.Client, ctx context.Context, fullPath string, info FileInfo, fileHash, fullHash string, checksums Checksums) error {
+	hashedKey := generateHash(fullPath)
+
+	fields := map[string]interface{}{
+		"path":  fullPath,
+		"size":  info.Size,
+		"mtime": info.ModTime.Unix(),
+	}
+	if fileHash != "" {
+		fields["partialHash"] = fileHash
+	}
+	if fullHash != "" {
+		fields["fullHash"] = fullHash
+	}
+	for algo, sum := range checksums.asMap() {
+		fields["checksums."+algo] = sum
+	}
+
+	pipe := rdb.Pipeline()
+	pipe.HSet(ctx, getFileRecordKey(hashedKey), fields)
+	if fileHash != "" {
+		pipe.SAdd(ctx, getFileHashKey(fileHash), fullPath)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error saving file record for %s: %w", fullPath, err)
+	}
+	return nil
+}
+
+// getFileRecord reads back fullPath's record in a single HGETALL, rather
+// than the three separate Gets (fileInfo:, hashedKeyToFileHash:,
+// hashedKeyToFullHash:) the legacy schema needs for the same information.
+func getFileRecord(rdb *redis.Client, ctx context.Context, fullPath string) (FileRecord, error) {
+	return getFileRecordByHashedKey(rdb, ctx, generateHash(fullPath))
+}
+
+func getFileRecordByHashedKey(rdb *redis.Client, ctx context.Context, hashedKey string) (FileRecord, error) {
+	raw, err := rdb.HGetAll(ctx, getFileRecordKey(hashedKey)).Result()
+	if err != nil {
+		return FileRecord{}, fmt.Errorf("error reading file record %s: %w", hashedKey, err)
+	}
+	if len(raw) == 0 {
+		return FileRecord{}, redis.Nil
+	}
+
+	record := FileRecord{
+		Path:        raw["path"],
+		PartialHash: raw["partialHash"],
+		FullHash:    raw["fullHash"],
+		Checksums: Checksums{
+			MD5:    raw["checksums.md5"],
+			SHA1:   raw["checksums.sha1"],
+			SHA256: raw["checksums.sha256"],
+			XXH3:   raw["checksums.xxh3"],
+		},
+	}
+	record.Size, _ = strconv.ParseInt(raw["size"], 10, 64)
+	if sec, err := strconv.ParseInt(raw["mtime"], 10, 64); err == nil {
+		record.ModTime = time.Unix(sec, 0)
+	}
+	return record, nil
+}
+
+// cleanUpFileRecord replaces cleanUpRecordsByFilePath for the Hash schema:
+// one HGETALL to read the derived keys a delete needs to also clean up,
+// then a single DEL plus whichever ZREM/SREM those fields imply - instead of
+// the legacy schema's four separate Get/Del round trips.
+func cleanUpFileRecord(rdb *redis.Client, ctx context.Context, fullPath string) error {
+	hashedKey := generateHash(fullPath)
+
+	record, err := getFileRecordByHashedKey(rdb, ctx, hashedKey)
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+
+	pipe := rdb.Pipeline()
+	pipe.Del(ctx, getFileRecordKey(hashedKey))
+	if record.PartialHash != "" {
+		pipe.SRem(ctx, getFileHashKey(record.PartialHash), fullPath)
+	}
+	if record.FullHash != "" {
+		pipe.ZRem(ctx, getDuplicateFilesKey(record.FullHash), fullPath)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("error cleaning up file record for %s: %w", fullPath, err)
+	}
+	return nil
+}
+
+// CheckSchemaVersion refuses to run against a Redis dataset recorded at
+// fileRecordSchemaVersion other than the one this binary understands,
+// mirroring CheckDatasetHashAlgo's guard against mixing hash algorithms. A
+// dataset with no schemaVersionKey at all predates the Hash schema (or is
+// fresh) and is accepted unconditionally - migrate it with
+// MigrateToHashSchema when ready.
+func CheckSchemaVersion(rdb *redis.Client, ctx context.Context) error {
+	stored, err := rdb.Get(ctx, schemaVersionKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+	if stored != fileRecordSchemaVersion {
+		return fmt.Errorf("redis dataset is schema version %q but this binary only understands %q; run with -migrate-to-hash-schema or rebuild against the matching revision", stored, fileRecordSchemaVersion)
+	}
+	return nil
+}
+
+// MigrateStats reports how many legacy per-file key groups
+// MigrateToHashSchema folded into file:<hashedKey> hashes, and how many it
+// skipped because one of the legacy keys was unreadable.
+type MigrateStats struct {
+	Migrated int
+	Errors   int
+}
+
+// MigrateToHashSchema scans every legacy fileInfo:<hashedKey> entry,
+// composes its file:<hashedKey> hash from the fileInfo/hashedKeyToFileHash/
+// hashedKeyToFullHash/checksums: keys recorded for the same hashedKey,
+// writes it via saveFileRecord, and deletes the legacy keys - including
+// hashedKeyToPath:/pathToHashedKey:, which the Hash schema has no use for
+// since hashedKey is always generateHash(path). It finishes by recording
+// fileRecordSchemaVersion at schemaVersionKey so CheckSchemaVersion accepts
+// the migrated dataset on the next run.
+func MigrateToHashSchema(rdb *redis.Client, ctx context.Context) (MigrateStats, error) {
+	var stats MigrateStats
+
+	iter := rdb.Scan(ctx, 0, keyPrefixFileInfo+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		hashedKey := strings.TrimPrefix(iter.Val(), keyPrefixFileInfo)
+
+		infoData, err := rdb.Get(ctx, getFileInfoKey(hashedKey)).Bytes()
+		if err != nil {
+			stats.Errors++
+			continue
+		}
+		var info FileInfo
+		if err := gob.NewDecoder(bytes.NewReader(infoData)).Decode(&info); err != nil {
+			stats.Errors++
+			continue
+		}
+
+		fileHash, fhErr := rdb.Get(ctx, getHashCacheKey(hashedKey)).Result()
+		if fhErr != nil && fhErr != redis.Nil {
+			stats.Errors++
+			continue
+		}
+		fullHash, fullErr := rdb.Get(ctx, getFullHashCacheKey(hashedKey)).Result()
+		if fullErr != nil && fullErr != redis.Nil {
+			stats.Errors++
+			continue
+		}
+
+		checksumFields, err := rdb.HGetAll(ctx, getChecksumsKey(hashedKey)).Result()
+		if err != nil && err != redis.Nil {
+			stats.Errors++
+			continue
+		}
+		checksums := Checksums{
+			MD5:    checksumFields["md5"],
+			SHA1:   checksumFields["sha1"],
+			SHA256: checksumFields["sha256"],
+			XXH3:   checksumFields["xxh3"],
+		}
+
+		if err := saveFileRecord(rdb, ctx, info.Path, info, fileHash, fullHash, checksums); err != nil {
+			stats.Errors++
+			continue
+		}
+
+		pipe := rdb.Pipeline()
+		pipe.Del(ctx, getFileInfoKey(hashedKey))
+		pipe.Del(ctx, getHashedKeyToPathKey(hashedKey))
+		pipe.Del(ctx, getPathToHashedKeyKey(info.Path))
+		pipe.Del(ctx, getHashCacheKey(hashedKey))
+		pipe.Del(ctx, getFullHashCacheKey(hashedKey))
+		pipe.Del(ctx, getChecksumsKey(hashedKey))
+		if _, err := pipe.Exec(ctx); err != nil {
+			stats.Errors++
+			continue
+		}
+
+		stats.Migrated++
+	}
+	if err := iter.Err(); err != nil {
+		return stats, fmt.Errorf("error scanning legacy file info keys: %w", err)
+	}
+
+	if err := rdb.Set(ctx, schemaVersionKey, fileRecordSchemaVersion, 0).Err(); err != nil {
+		return stats, fmt.Errorf("error recording schema version: %w", err)
+	}
+
+	return stats, nil
+}
+
+// asMap returns c's non-blank fields keyed by the lowercase algorithm name
+// saveFileRecord/getFileRecordByHashedKey use for the "checksums.<algo>"
+// hash fields.
+func (c Checksums) asMap() map[string]string {
+	m := make(map[string]string, 4)
+	if c.MD5 != "" {
+		m["md5"] = c.MD5
+	}
+	if c.SHA1 != "" {
+		m["sha1"] = c.SHA1
+	}
+	if c.SHA256 != "" {
+		m["sha256"] = c.SHA256
+	}
+	if c.XXH3 != "" {
+		m["xxh3"] = c.XXH3
+	}
+	return m
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveFileRecord_RoundTrip(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	path := "/path/to/testfile.txt"
+	modTime := time.Now().Truncate(time.Second)
+	info := FileInfo{Size: 1024, ModTime: modTime, Path: path}
+	checksums := Checksums{MD5: "abc", SHA256: "def"}
+
+	require.NoError(t, saveFileRecord(rdb, ctx, path, info, "partialhash", "fullhash", checksums))
+
+	hashedKey := generateHash(path)
+	assert.True(t, mr.Exists("file:"+hashedKey))
+	assert.False(t, mr.Exists("fileInfo:"+hashedKey), "the hash schema should need no legacy fileInfo: key")
+	assert.False(t, mr.Exists("hashedKeyToPath:"+hashedKey), "hashedKey is derivable from path, so no reverse mapping should be written")
+
+	record, err := getFileRecord(rdb, ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, path, record.Path)
+	assert.Equal(t, int64(1024), record.Size)
+	assert.Equal(t, modTime.Unix(), record.ModTime.Unix())
+	assert.Equal(t, "partialhash", record.PartialHash)
+	assert.Equal(t, "fullhash", record.FullHash)
+	assert.Equal(t, checksums, record.Checksums)
+
+	isMember, err := mr.SIsMember("fileHashToPathSet:partialhash", path)
+	require.NoError(t, err)
+	assert.True(t, isMember)
+}
+
+func TestGetFileRecord_MissingIsRedisNil(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	_, err = getFileRecord(rdb, ctx, "/does/not/exist")
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestCleanUpFileRecord_RemovesRecordAndDerivedMemberships(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	path := "/path/to/testfile.txt"
+	info := FileInfo{Size: 1024, ModTime: time.Now(), Path: path}
+	require.NoError(t, saveFileRecord(rdb, ctx, path, info, "partialhash", "fullhash", Checksums{}))
+	require.NoError(t, SaveDuplicateFileInfoToRedis(rdb, ctx, "fullhash", info))
+
+	require.NoError(t, cleanUpFileRecord(rdb, ctx, path))
+
+	hashedKey := generateHash(path)
+	assert.False(t, mr.Exists("file:"+hashedKey))
+
+	// SREM removing the set's only member deletes the key entirely, and
+	// miniredis's SIsMember helper errors on a missing key rather than
+	// reporting non-membership, so check existence through the real client
+	// instead.
+	isMember, err := rdb.SIsMember(ctx, "fileHashToPathSet:partialhash", path).Result()
+	require.NoError(t, err)
+	assert.False(t, isMember)
+
+	members, err := rdb.ZRange(ctx, "duplicateFiles:fullhash", 0, -1).Result()
+	require.NoError(t, err)
+	assert.NotContains(t, members, path)
+}
+
+func TestCleanUpFileRecord_MissingRecordIsNoOp(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	assert.NoError(t, cleanUpFileRecord(rdb, ctx, "/does/not/exist"))
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	assert.NoError(t, CheckSchemaVersion(rdb, ctx), "a dataset with no schema version recorded should be accepted")
+
+	require.NoError(t, rdb.Set(ctx, schemaVersionKey, fileRecordSchemaVersion, 0).Err())
+	assert.NoError(t, CheckSchemaVersion(rdb, ctx))
+
+	require.NoError(t, rdb.Set(ctx, schemaVersionKey, "99", 0).Err())
+	assert.Error(t, CheckSchemaVersion(rdb, ctx))
+}
+
+func TestMigrateToHashSchema(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	path := "/path/to/legacy.txt"
+	info := FileInfo{Size: 2048, ModTime: time.Now().Truncate(time.Second), Path: path}
+	require.NoError(t, saveFileInfoToRedis(rdb, ctx, path, info, "partialhash", "fullhash", true))
+	require.NoError(t, saveChecksums(rdb, ctx, generateHash(path), Checksums{MD5: "abc"}))
+
+	stats, err := MigrateToHashSchema(rdb, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Migrated)
+	assert.Equal(t, 0, stats.Errors)
+
+	hashedKey := generateHash(path)
+	assert.False(t, mr.Exists("fileInfo:"+hashedKey))
+	assert.False(t, mr.Exists("hashedKeyToPath:"+hashedKey))
+	assert.False(t, mr.Exists("pathToHashedKey:"+path))
+	assert.False(t, mr.Exists("checksums:"+hashedKey))
+
+	record, err := getFileRecord(rdb, ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2048), record.Size)
+	assert.Equal(t, "partialhash", record.PartialHash)
+	assert.Equal(t, "fullhash", record.FullHash)
+	assert.Equal(t, "abc", record.Checksums.MD5)
+
+	version, err := rdb.Get(ctx, schemaVersionKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, fileRecordSchemaVersion, version)
+}
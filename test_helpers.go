@@ -2,19 +2,17 @@
 
 package main
 
-import "regexp"
-
-var testExcludeRegexps []*regexp.Regexp
+var testExcludeRules *ExcludeRuleSet
 
 func init() {
 	// 初始化测试用的 exclude patterns
 	patterns := []string{
-		`.*\.git(/.*)?$`,
-		`.*snapraid\.parity(/.*)?$`,
-		`^./sda1/.*`,
+		`re:.*\.git(/.*)?$`,
+		`re:.*snapraid\.parity(/.*)?$`,
+		`re:^./sda1/.*`,
 	}
 	var err error
-	testExcludeRegexps, err = compileExcludePatterns(patterns)
+	testExcludeRules, err = compileExcludePatterns(patterns)
 	if err != nil {
 		panic("Failed to compile test exclude patterns: " + err.Error())
 	}
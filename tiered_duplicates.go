@@ -0,0 +1,240 @@
+// tiered_duplicates.go
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultHeadSampleSize is how many bytes ProcessFileTiered reads from the
+// start and end of a file for its head-sample digest, when
+// FileProcessor.HeadSampleSize is unset.
+const defaultHeadSampleSize = 4 * 1024
+
+const (
+	// keyPrefixBySize namespaces stage one's exact-size buckets:
+	// bySize:<size> is a set of every path Stat reported that size for.
+	keyPrefixBySize = "bySize:"
+	// keyPrefixByHead namespaces stage two's head-sample buckets, scoped
+	// under the size that produced them so files of different sizes never
+	// collide in the same bucket even if their head samples happen to match.
+	keyPrefixByHead = "byHead:"
+	// keyPrefixHeadSampleCache caches ProcessFileTiered's own head-sample
+	// digest per file, so a re-run doesn't re-read the head/tail bytes of a
+	// file whose size bucket it has already classified.
+	keyPrefixHeadSampleCache = "headSample:"
+)
+
+func getBySizeKey(size int64) string {
+	return fmt.Sprintf("%s%d", keyPrefixBySize, size)
+}
+
+func getByHeadKey(size int64, headHash string) string {
+	return fmt.Sprintf("%s%d:%s", keyPrefixByHead, size, headHash)
+}
+
+func getHeadSampleCacheKey(hashedKey string) string {
+	return keyPrefixHeadSampleCache + hashedKey
+}
+
+// headSampleSize returns fp.HeadSampleSize, or defaultHeadSampleSize if unset.
+func (fp *FileProcessor) headSampleSize() int64 {
+	if fp.HeadSampleSize > 0 {
+		return fp.HeadSampleSize
+	}
+	return defaultHeadSampleSize
+}
+
+// WithHeadSampleSize overrides how many bytes ProcessFileTiered samples from
+// each end of a file for its stage-two head-sample digest.
+func WithHeadSampleSize(size int64) func(*FileProcessor) {
+	return func(fp *FileProcessor) { fp.HeadSampleSize = size }
+}
+
+// headSampleDigest hashes the first and last sampleSize bytes of fullPath
+// (the whole file if it's smaller than 2*sampleSize), cheap enough to run
+// against every candidate in a size bucket without the full-file read stage
+// three reserves for files that also survive this one.
+func headSampleDigest(fp *FileProcessor, fullPath string, size, sampleSize int64) (string, error) {
+	f, err := fp.fs.Open(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("error opening %s for head sample: %w", fullPath, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, sampleSize); err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading head of %s: %w", fullPath, err)
+	}
+
+	if tailStart := size - sampleSize; tailStart > sampleSize {
+		if _, err := f.Seek(tailStart, io.SeekStart); err != nil {
+			return "", fmt.Errorf("error seeking tail of %s: %w", fullPath, err)
+		}
+		if _, err := io.CopyN(h, f, sampleSize); err != nil && err != io.EOF {
+			return "", fmt.Errorf("error reading tail of %s: %w", fullPath, err)
+		}
+	} else if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("error reading rest of %s: %w", fullPath, err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ProcessFileTiered classifies fullPath into stage one's exact-size bucket
+// with a Stat only, deferring every byte read to FindDuplicatesTiered so a
+// walk that calls this for every file stays as cheap as the existing
+// ProcessFile's partial-hash tier, not more expensive.
+func (fp *FileProcessor) ProcessFileTiered(fullPath string) error {
+	info, err := fp.fs.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("error getting file info: %w", err)
+	}
+
+	if err := fp.Rdb.SAdd(fp.Ctx, getBySizeKey(info.Size()), fullPath).Err(); err != nil {
+		return fmt.Errorf("error adding %s to size bucket: %w", fullPath, err)
+	}
+
+	return nil
+}
+
+// TieredDuplicateStats counts how many candidate files each stage of
+// FindDuplicatesTiered eliminated, so callers can report the pipeline's win
+// over hashing every file in full.
+type TieredDuplicateStats struct {
+	FilesConsidered    int
+	EliminatedBySize   int
+	EliminatedByHead   int
+	FullHashesComputed int
+}
+
+// TieredDuplicateGroup is a set of paths FindDuplicatesTiered confirmed share
+// a full content hash, having already survived the size and head-sample
+// elimination stages.
+type TieredDuplicateGroup struct {
+	FullHash string
+	Paths    []string
+}
+
+// FindDuplicatesTiered groups every file ProcessFileTiered has classified
+// into confirmed duplicate sets, reading as little of each file as possible:
+// a bySize:<size> bucket with only one member is dropped without opening any
+// file; a surviving bucket's members each get a cheap head/tail sample
+// (cached under headSample:<hashedKey> so a re-run skips files it already
+// sampled); a byHead:<size>:<headHash> bucket with only one member is
+// likewise dropped; only files that survive both stages get a full-file hash
+// via calculateFileHashFunc, the same tier ProcessFile's non-tiered path
+// always pays for every candidate.
+func (fp *FileProcessor) FindDuplicatesTiered() ([]TieredDuplicateGroup, TieredDuplicateStats, error) {
+	var stats TieredDuplicateStats
+
+	sizeBuckets, err := fp.scanSetsByPrefix(keyPrefixBySize)
+	if err != nil {
+		return nil, stats, fmt.Errorf("error scanning size buckets: %w", err)
+	}
+
+	headBuckets := make(map[string][]string)
+	for sizeKey, paths := range sizeBuckets {
+		stats.FilesConsidered += len(paths)
+		if len(paths) < 2 {
+			stats.EliminatedBySize += len(paths)
+			continue
+		}
+
+		size, err := parseBySizeKey(sizeKey)
+		if err != nil {
+			continue
+		}
+		sampleSize := fp.headSampleSize()
+
+		for _, path := range paths {
+			headHash, err := fp.cachedHeadSampleDigest(path, size, sampleSize)
+			if err != nil {
+				continue
+			}
+			key := getByHeadKey(size, headHash)
+			headBuckets[key] = append(headBuckets[key], path)
+		}
+	}
+
+	var groups []TieredDuplicateGroup
+	fullHashGroups := make(map[string][]string)
+	for _, paths := range headBuckets {
+		if len(paths) < 2 {
+			stats.EliminatedByHead += len(paths)
+			continue
+		}
+
+		for _, path := range paths {
+			fullHash, err := fp.calculateFileHashFunc(path, FullFileReadCmd)
+			if err != nil {
+				continue
+			}
+			stats.FullHashesComputed++
+			fullHashGroups[fullHash] = append(fullHashGroups[fullHash], path)
+		}
+	}
+
+	for fullHash, paths := range fullHashGroups {
+		if len(paths) > 1 {
+			groups = append(groups, TieredDuplicateGroup{FullHash: fullHash, Paths: paths})
+		}
+	}
+
+	return groups, stats, nil
+}
+
+// cachedHeadSampleDigest returns path's head-sample digest, computing and
+// caching it under headSample:<hashedKey> on first use so a re-run against
+// the same file doesn't re-read its head/tail bytes.
+func (fp *FileProcessor) cachedHeadSampleDigest(path string, size, sampleSize int64) (string, error) {
+	hashedKey := fp.generateHashFunc(path)
+	cacheKey := getHeadSampleCacheKey(hashedKey)
+
+	if cached, err := fp.Rdb.Get(fp.Ctx, cacheKey).Result(); err == nil {
+		return cached, nil
+	}
+
+	digest, err := headSampleDigest(fp, path, size, sampleSize)
+	if err != nil {
+		return "", err
+	}
+
+	if err := fp.Rdb.Set(fp.Ctx, cacheKey, digest, 0).Err(); err != nil {
+		return "", fmt.Errorf("error caching head sample for %s: %w", path, err)
+	}
+
+	return digest, nil
+}
+
+// scanSetsByPrefix returns every Redis set key under prefix, mapped to its
+// members, the same scan-then-SMembers shape scanFileHashes uses for
+// fileHashToPathSet:*.
+func (fp *FileProcessor) scanSetsByPrefix(prefix string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	iter := fp.Rdb.Scan(fp.Ctx, 0, prefix+"*", 0).Iterator()
+	for iter.Next(fp.Ctx) {
+		key := iter.Val()
+		members, err := fp.Rdb.SMembers(fp.Ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error reading members of %s: %w", key, err)
+		}
+		result[key] = members
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning %s*: %w", prefix, err)
+	}
+	return result, nil
+}
+
+// parseBySizeKey extracts the size encoded in a bySize:<size> key.
+func parseBySizeKey(key string) (int64, error) {
+	raw := strings.TrimPrefix(key, keyPrefixBySize)
+	var size int64
+	if _, err := fmt.Sscanf(raw, "%d", &size); err != nil {
+		return 0, fmt.Errorf("error parsing size from key %s: %w", key, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProcessor_FindDuplicatesTiered_GroupsMatchingFiles(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("same content, same size!"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("same content, same size!"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/unique.txt", []byte("nothing else matches this one"), 0644))
+
+	for _, path := range []string{"/a.txt", "/b.txt", "/unique.txt"} {
+		require.NoError(t, fp.ProcessFileTiered(path))
+	}
+
+	groups, stats, err := fp.FindDuplicatesTiered()
+	require.NoError(t, err)
+
+	require.Len(t, groups, 1)
+	assert.ElementsMatch(t, []string{"/a.txt", "/b.txt"}, groups[0].Paths)
+	assert.Equal(t, 1, stats.EliminatedBySize)
+	assert.GreaterOrEqual(t, stats.FullHashesComputed, 2)
+}
+
+func TestFileProcessor_FindDuplicatesTiered_SameSizeDifferentContentEliminatedByHead(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("aaaaaaaaaa"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("bbbbbbbbbb"), 0644))
+
+	require.NoError(t, fp.ProcessFileTiered("/a.txt"))
+	require.NoError(t, fp.ProcessFileTiered("/b.txt"))
+
+	groups, stats, err := fp.FindDuplicatesTiered()
+	require.NoError(t, err)
+
+	assert.Empty(t, groups)
+	assert.Equal(t, 0, stats.EliminatedBySize)
+	assert.Equal(t, 2, stats.EliminatedByHead)
+	assert.Equal(t, 0, stats.FullHashesComputed)
+}
+
+func TestFileProcessor_CachedHeadSampleDigest_ReusesCacheOnRerun(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("content"), 0644))
+
+	digest1, err := fp.cachedHeadSampleDigest("/a.txt", 7, fp.headSampleSize())
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Remove("/a.txt")) // prove the second call doesn't re-read the file
+	digest2, err := fp.cachedHeadSampleDigest("/a.txt", 7, fp.headSampleSize())
+	require.NoError(t, err)
+
+	assert.Equal(t, digest1, digest2)
+}
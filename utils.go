@@ -4,12 +4,10 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/sha512"
 	"encoding/gob"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/spf13/afero"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -23,7 +21,7 @@ import (
 
 var mu sync.Mutex
 
-func findAndLogDuplicates(rootDir string, rdb *redis.Client, ctx context.Context, maxDuplicates int, excludeRegexps []*regexp.Regexp, fs afero.Fs) error {
+func findAndLogDuplicates(rootDir string, rdb *redis.Client, ctx context.Context, maxDuplicates int, excludeRules *ExcludeRuleSet, fs afero.Fs, hashType HashType) error {
 	log.Println("Starting findAndLogDuplicates function")
 	fileHashes, err := scanFileHashes(rdb, ctx)
 	if err != nil {
@@ -37,7 +35,16 @@ func findAndLogDuplicates(rootDir string, rdb *redis.Client, ctx context.Context
 	var stopProcessing bool
 	taskQueue, poolWg, stopFunc, _ := NewWorkerPool(workerCount, &stopProcessing)
 
-	for fileHash, filePaths := range fileHashes {
+	fp := CreateFileProcessor(rdb, ctx, excludeRules, WithFilesystem(fs))
+
+	for fileHash, allPaths := range fileHashes {
+		filePaths := make([]string, 0, len(allPaths))
+		for _, path := range allPaths {
+			if !fp.ShouldExclude(path) {
+				filePaths = append(filePaths, path)
+			}
+		}
+
 		if len(filePaths) > 1 {
 			select {
 			case <-ctx.Done():
@@ -55,7 +62,7 @@ func findAndLogDuplicates(rootDir string, rdb *redis.Client, ctx context.Context
 				taskQueue <- func(fileHash string, filePaths []string) Task {
 					return func() {
 						log.Printf("Processing hash %s with %d files\n", fileHash, len(filePaths))
-						_, err := processFileHash(rootDir, fileHash, filePaths, rdb, ctx, processedFullHashes, fs)
+						_, err := processFileHash(rootDir, fileHash, filePaths, rdb, ctx, processedFullHashes, fs, hashType)
 						if err != nil {
 							log.Printf("Error processing file hash %s: %s\n", fileHash, err)
 						}
@@ -92,8 +99,8 @@ func findAndLogDuplicates(rootDir string, rdb *redis.Client, ctx context.Context
 	return nil
 }
 
-func getFileSizeFromRedis(rdb *redis.Client, ctx context.Context, rootDir, relativePath string, excludeRegexps []*regexp.Regexp) (int64, error) {
-	fp := CreateFileProcessor(rdb, ctx, excludeRegexps)
+func getFileSizeFromRedis(rdb *redis.Client, ctx context.Context, rootDir, relativePath string, excludeRules *ExcludeRuleSet) (int64, error) {
+	fp := CreateFileProcessor(rdb, ctx, excludeRules)
 	fullPath := filepath.Join(rootDir, relativePath)
 	hashedKey, err := fp.getHashedKeyFromPath(fullPath)
 	if err != nil {
@@ -116,35 +123,6 @@ func getFileSizeFromRedis(rdb *redis.Client, ctx context.Context, rootDir, relat
 	return fileInfo.Size, nil
 }
 
-func getFullFileHash(fs afero.Fs, path string, rdb *redis.Client, ctx context.Context) (string, error) {
-	return calculateFileHash(fs, path, -1)
-}
-
-func getFileHash(fs afero.Fs, path string, rdb *redis.Client, ctx context.Context) (string, error) {
-	return calculateFileHash(fs, path, 100*1024) // 100KB
-}
-
-func calculateFileHash(fs afero.Fs, path string, limit int64) (string, error) {
-	f, err := fs.Open(path)
-	if err != nil {
-		return "", fmt.Errorf("error opening file %q: %w", path, err)
-	}
-	defer f.Close()
-
-	h := sha512.New()
-	if limit == -1 {
-		if _, err := io.Copy(h, f); err != nil {
-			return "", fmt.Errorf("error reading full file %q: %w", path, err)
-		}
-	} else {
-		if _, err := io.CopyN(h, f, limit); err != nil && err != io.EOF {
-			return "", fmt.Errorf("error reading file %q: %w", path, err)
-		}
-	}
-
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
-}
-
 func ExtractTimestamps(filePath string) []string {
 	pattern := regexp.MustCompile(`[:,/](\d{1,2}(?::\d{1,2}){1,2})`)
 	matches := pattern.FindAllStringSubmatch(filePath, -1)
@@ -261,7 +239,7 @@ type fileInfo struct {
 	FileInfo  // 嵌入已有的FileInfo结构体
 }
 
-func processFileHash(rootDir string, fileHash string, filePaths []string, rdb *redis.Client, ctx context.Context, processedFullHashes *sync.Map, fs afero.Fs) (int, error) {
+func processFileHash(rootDir string, fileHash string, filePaths []string, rdb *redis.Client, ctx context.Context, processedFullHashes *sync.Map, fs afero.Fs, hashType HashType) (int, error) {
 	log.Printf("Starting processFileHash for hash: %s", fileHash)
 	fileCount := 0
 	hashes := make(map[string][]fileInfo)
@@ -278,13 +256,13 @@ func processFileHash(rootDir string, fileHash string, filePaths []string, rdb *r
 		}
 		fileName := filepath.Base(relativePath)
 
-		fullHash, err := getFullFileHash(fs, fullPath, rdb, ctx)
-		if err != nil {
-			log.Printf("Error getting full hash for file %s: %v", fullPath, err)
-			continue
-		}
-		localFileHash, err := getFileHash(fs, fullPath, rdb, ctx) // 使用不同的变量名，避免与参数冲突
+		// 使用不同的变量名，避免与参数冲突; computeFileDigests opens fullPath
+		// exactly once for both the head and full hash, plus any configured
+		// checksum algorithms, instead of the two separate opens
+		// getFullFileHash/getFileHash used to require.
+		localFileHash, fullHash, checksums, err := computeFileDigests(fs, fullPath, hashType, checksumAlgos)
 		if err != nil {
+			log.Printf("Error computing digests for file %s: %v", fullPath, err)
 			continue
 		}
 		info, err = fs.Stat(fullPath)
@@ -304,6 +282,9 @@ func processFileHash(rootDir string, fileHash string, filePaths []string, rdb *r
 		}, localFileHash, fullHash, true); err != nil {
 			continue
 		}
+		if err := saveChecksums(rdb, ctx, generateHash(fullPath), checksums); err != nil {
+			log.Printf("Error saving checksums for file %s: %v", fullPath, err)
+		}
 		infoStruct := fileInfo{
 			name:      fileName,
 			path:      fullPath,
@@ -321,6 +302,7 @@ func processFileHash(rootDir string, fileHash string, filePaths []string, rdb *r
 	for fullHash, infos := range hashes {
 		if len(infos) > 1 {
 			if _, loaded := processedFullHashes.LoadOrStore(fullHash, true); !loaded {
+				metrics.RecordDuplicateGroup(infos[0].Size, len(infos))
 				for _, info := range infos {
 					log.Printf("Saving duplicate file info to Redis for file: %s", info.path)
 					err := SaveDuplicateFileInfoToRedis(rdb, ctx, fullHash, info.FileInfo)
@@ -363,23 +345,32 @@ func scanFileHashes(rdb *redis.Client, ctx context.Context) (map[string][]string
 	return fileHashes, nil
 }
 
-func writeDuplicateFilesToFile(rootDir string, outputFile string, rdb *redis.Client, ctx context.Context, excludeRegexps []*regexp.Regexp) error {
+func writeDuplicateFilesToFile(rootDir string, outputFile string, rdb *redis.Client, ctx context.Context, excludeRules *ExcludeRuleSet) error {
 	file, err := os.Create(filepath.Join(rootDir, outputFile))
 	if err != nil {
 		return fmt.Errorf("Error creating output file: %s", err)
 	}
 	defer file.Close()
 
+	fp := CreateFileProcessor(rdb, ctx, excludeRules)
+
 	iter := rdb.Scan(ctx, 0, "duplicateFiles:*", 0).Iterator()
 	for iter.Next(ctx) {
 		duplicateFilesKey := iter.Val()
 		fullHash := strings.TrimPrefix(duplicateFilesKey, "duplicateFiles:")
-		duplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
+		allDuplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
 		if err != nil {
 			log.Printf("Error getting duplicate files for key %s: %v", duplicateFilesKey, err)
 			continue
 		}
 
+		duplicateFiles := make([]string, 0, len(allDuplicateFiles))
+		for _, path := range allDuplicateFiles {
+			if !fp.ShouldExclude(path) {
+				duplicateFiles = append(duplicateFiles, path)
+			}
+		}
+
 		if len(duplicateFiles) > 1 {
 			header := fmt.Sprintf("Duplicate files for fullHash %s:\n", fullHash)
 			if _, err := file.WriteString(header); err != nil {
@@ -387,8 +378,6 @@ func writeDuplicateFilesToFile(rootDir string, outputFile string, rdb *redis.Cli
 				continue
 			}
 
-			fp := CreateFileProcessor(rdb, ctx, excludeRegexps)
-
 			for i, duplicateFile := range duplicateFiles {
 				hashedKey, err := fp.getHashedKeyFromPath(duplicateFile)
 				if err != nil {
@@ -458,6 +447,9 @@ func extractKeywords(fileNames []string, stopProcessing *bool) []string {
 				}()
 
 				nameWithoutExt := strings.TrimSuffix(name, filepath.Ext(name))
+				// NFC-normalize so accented/CJK filenames compare consistently;
+				// NormalizeOptions{} leaves case and separators untouched.
+				nameWithoutExt = NormalizeFileName(nameWithoutExt, NormalizeOptions{})
 				matches := pattern.FindAllString(nameWithoutExt, -1)
 				for _, match := range matches {
 					keywordsCh <- match
@@ -489,7 +481,9 @@ func extractKeywords(fileNames []string, stopProcessing *bool) []string {
 	return keywords
 }
 
-func deleteDuplicateFiles(rootDir string, rdb *redis.Client, ctx context.Context, fs afero.Fs) error {
+func deleteDuplicateFiles(rootDir string, rdb *redis.Client, ctx context.Context, fs afero.Fs, excludeRules *ExcludeRuleSet) error {
+	fp := CreateFileProcessor(rdb, ctx, excludeRules, WithFilesystem(fs))
+
 	iter := rdb.Scan(ctx, 0, "duplicateFiles:*", 0).Iterator()
 	for iter.Next(ctx) {
 		duplicateFilesKey := iter.Val()
@@ -498,11 +492,18 @@ func deleteDuplicateFiles(rootDir string, rdb *redis.Client, ctx context.Context
 		fullHash := strings.TrimPrefix(duplicateFilesKey, "duplicateFiles:")
 
 		// 获取重复文件列表
-		duplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
+		allDuplicateFiles, err := rdb.ZRange(ctx, duplicateFilesKey, 0, -1).Result()
 		if err != nil {
 			continue
 		}
 
+		duplicateFiles := make([]string, 0, len(allDuplicateFiles))
+		for _, path := range allDuplicateFiles {
+			if !fp.ShouldExclude(path) {
+				duplicateFiles = append(duplicateFiles, path)
+			}
+		}
+
 		if len(duplicateFiles) > 1 {
 			// 保留第一个文件（你可以根据自己的需求修改保留策略）
 			fileToKeep := duplicateFiles[0]
@@ -523,6 +524,7 @@ func deleteDuplicateFiles(rootDir string, rdb *redis.Client, ctx context.Context
 					log.Printf("Error deleting file %s: %v", filePath, err)
 				} else {
 					log.Printf("Deleted duplicate file: %s", filePath)
+					metrics.IncFilesDeleted()
 				}
 			}
 
@@ -8,7 +8,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -163,14 +162,14 @@ func TestWalkFiles(t *testing.T) {
 
 	// 创建一个 FileProcessor 实例，不包含任何排除规则
 	fp := &FileProcessor{
-		Rdb:            rdb,
-		Ctx:            context.Background(),
-		excludeRegexps: []*regexp.Regexp{},
+		Rdb:          rdb,
+		Ctx:          context.Background(),
+		excludeRules: &ExcludeRuleSet{},
 	}
 
 	fileChan := make(chan string, 10)
 	go func() {
-		err := walkFiles(tempDir, 100, fileChan, fp)
+		err := walkFiles(context.Background(), tempDir, 100, fileChan, fp, nil)
 		assert.NoError(t, err)
 		close(fileChan)
 	}()
@@ -305,7 +304,7 @@ func TestGetFileSizeFromRedis(t *testing.T) {
 			relativePath, err := filepath.Rel(tempDir, filePath)
 			require.NoError(t, err)
 
-			size, err := getFileSizeFromRedis(rdb, ctx, tempDir, relativePath, testExcludeRegexps)
+			size, err := getFileSizeFromRedis(rdb, ctx, tempDir, relativePath, testExcludeRules)
 			assert.NoError(t, err)
 			assert.Equal(t, int64(len(sf.content)), size)
 		})
@@ -313,7 +312,7 @@ func TestGetFileSizeFromRedis(t *testing.T) {
 
 	// Test with non-existent file
 	t.Run("GetSize_NonExistentFile", func(t *testing.T) {
-		_, err := getFileSizeFromRedis(rdb, ctx, tempDir, "non-existent-file.txt", testExcludeRegexps)
+		_, err := getFileSizeFromRedis(rdb, ctx, tempDir, "non-existent-file.txt", testExcludeRules)
 		assert.Error(t, err)
 	})
 }
@@ -352,10 +351,10 @@ func TestWalkFilesWithExcludePatterns(t *testing.T) {
 	}
 
 	excludePatterns := []string{
-		`.*\.git/.*`,
-		`.*small_file\.txt$`,
+		`re:.*\.git/.*`,
+		`re:.*small_file\.txt$`,
 	}
-	excludeRegexps, err := compileExcludePatterns(excludePatterns)
+	excludeRules, err := compileExcludePatterns(excludePatterns)
 	require.NoError(t, err)
 
 	var logBuf bytes.Buffer
@@ -373,10 +372,10 @@ func TestWalkFilesWithExcludePatterns(t *testing.T) {
 	defer rdb.Close()
 
 	fileChan := make(chan string, 10)
-	fp := CreateFileProcessor(rdb, context.Background(), excludeRegexps)
+	fp := CreateFileProcessor(rdb, context.Background(), excludeRules)
 
 	go func() {
-		err := walkFiles(tempDir, 100, fileChan, fp)
+		err := walkFiles(context.Background(), tempDir, 100, fileChan, fp, nil)
 		assert.NoError(t, err)
 		close(fileChan)
 	}()
@@ -452,7 +451,7 @@ func TestFindAndLogDuplicates(t *testing.T) {
 	ctx := context.Background()
 
 	fs := afero.NewMemMapFs()
-	fp := CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	rootDir, err := afero.TempDir(fs, "", "testroot")
@@ -476,12 +475,12 @@ func TestFindAndLogDuplicates(t *testing.T) {
 	for _, tf := range testFiles {
 		relPath, err := filepath.Rel(rootDir, tf.path)
 		require.NoError(t, err)
-		err = fp.ProcessFile(rootDir, relPath)
+		err = fp.ProcessFile(rootDir, relPath, true)
 		require.NoError(t, err)
 	}
 
 	// 调用 findAndLogDuplicates 时传递 fs
-	err = findAndLogDuplicates(rootDir, rdb, ctx, 10, testExcludeRegexps, fp.fs)
+	err = findAndLogDuplicates(rootDir, rdb, ctx, 10, testExcludeRules, fp.fs, DefaultHashType)
 	require.NoError(t, err)
 
 	// 检查是否在 Redis 中正确存储了重复文件信息
@@ -510,7 +509,7 @@ func TestDeleteDuplicateFiles(t *testing.T) {
 	ctx := context.Background()
 
 	fs := afero.NewMemMapFs()
-	fp := CreateFileProcessor(rdb, ctx, testExcludeRegexps)
+	fp := CreateFileProcessor(rdb, ctx, testExcludeRules)
 	fp.fs = fs
 
 	rootDir, err := afero.TempDir(fs, "", "testroot")
@@ -546,7 +545,7 @@ func TestDeleteDuplicateFiles(t *testing.T) {
 	}
 
 	// 执行删除重复文件的函数
-	err = deleteDuplicateFiles(rootDir, rdb, ctx, fp.fs)
+	err = deleteDuplicateFiles(rootDir, rdb, ctx, fp.fs, testExcludeRules)
 	require.NoError(t, err)
 
 	// 检查文件是否被删除
@@ -0,0 +1,218 @@
+// walk_cache.go
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// WalkCacheEntry records one directory child's name, size, type, and ModTime
+// as observed during a previous walkFilesCached run.
+type WalkCacheEntry struct {
+	Name    string
+	Size    int64
+	IsDir   bool
+	ModTime time.Time
+}
+
+// WalkCacheDir records one directory's own ModTime and its child list as
+// observed during a previous walkFilesCached run, so a later run can reuse
+// the child list - skipping a full, per-child-stat Readdir - whenever the
+// directory's ModTime is unchanged and its current entry names still match.
+type WalkCacheDir struct {
+	ModTime time.Time
+	Entries []WalkCacheEntry
+}
+
+// WalkCache is a persistent, rootDir-scoped cache of directory listings for
+// --walk-cache, keyed by each directory's path relative to rootDir ("" for
+// rootDir itself). See LoadWalkCache/SaveWalkCache.
+type WalkCache struct {
+	Dirs map[string]WalkCacheDir
+}
+
+// NewWalkCache returns an empty WalkCache ready to populate during a walk.
+func NewWalkCache() *WalkCache {
+	return &WalkCache{Dirs: make(map[string]WalkCacheDir)}
+}
+
+// LoadWalkCache reads a WalkCache previously written by SaveWalkCache from
+// path. A missing file isn't an error - it returns a fresh, empty cache, so
+// the first run against a given --walk-cache path behaves exactly like no
+// cache was configured.
+func LoadWalkCache(fs afero.Fs, path string) (*WalkCache, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewWalkCache(), nil
+		}
+		return nil, fmt.Errorf("error opening walk cache %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var cache WalkCache
+	if err := gob.NewDecoder(f).Decode(&cache); err != nil {
+		return nil, fmt.Errorf("error decoding walk cache %s: %w", path, err)
+	}
+	if cache.Dirs == nil {
+		cache.Dirs = make(map[string]WalkCacheDir)
+	}
+	return &cache, nil
+}
+
+// SaveWalkCache writes cache to path using the write-tmp/rotate-old/rename
+// pattern in atomicWriteFile, rotating the previous cache to "<path>.old"
+// first - the same dir-cache rotation atomic_write.go already uses for other
+// on-disk caches and logs. Callers must only call SaveWalkCache after a walk
+// completes without error; saving the partial view from an interrupted walk
+// would poison the cache with directories that were never fully visited.
+func SaveWalkCache(fs afero.Fs, path string, cache *WalkCache, keepRotations int) error {
+	return atomicWriteFile(fs, path, keepRotations, false, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(cache)
+	})
+}
+
+// walkFilesCached walks rootDir like walkFiles, but consults cache to reuse
+// a directory's previously observed child list - and feed its unchanged,
+// already-known-large files straight to fileChan without re-Stat - whenever
+// the directory itself is unchanged. It returns the WalkCache this run
+// observed, for the caller to persist via SaveWalkCache once the walk
+// completes successfully.
+func walkFilesCached(ctx context.Context, rootDir string, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor, checkpoint *Checkpoint, cache *WalkCache) (*WalkCache, error) {
+	next := NewWalkCache()
+	err := walkDirCached(ctx, rootDir, rootDir, "", minSizeBytes, fileChan, fp, checkpoint, cache, next)
+	return next, err
+}
+
+func walkDirCached(ctx context.Context, rootDir, dir, relDir string, minSizeBytes int64, fileChan chan<- string, fp *FileProcessor, checkpoint *Checkpoint, cache, next *WalkCache) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	dirInfo, err := fp.fs.Stat(dir)
+	if err != nil {
+		log.Printf("Error accessing directory %q: %v", dir, err)
+		return nil
+	}
+
+	entries, reused, err := readDirCached(fp.fs, dir, relDir, dirInfo.ModTime(), cache)
+	if err != nil {
+		log.Printf("Error reading directory %q: %v", dir, err)
+		return nil
+	}
+	next.Dirs[relDir] = WalkCacheDir{ModTime: dirInfo.ModTime(), Entries: entries}
+	if reused {
+		metrics.IncWalkCacheDirHits()
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		childPath := filepath.Join(dir, entry.Name)
+
+		if entry.IsDir {
+			if fp.ShouldExclude(childPath) {
+				continue
+			}
+			childRel := filepath.Join(relDir, entry.Name)
+			if err := walkDirCached(ctx, rootDir, childPath, childRel, minSizeBytes, fileChan, fp, checkpoint, cache, next); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fp.ShouldExclude(childPath) {
+			metrics.IncFilesSkippedExcluded()
+			continue
+		}
+
+		if entry.Size < minSizeBytes {
+			metrics.IncFilesSkippedMinSize()
+			continue
+		}
+
+		relPath, err := filepath.Rel(rootDir, childPath)
+		if err != nil {
+			log.Printf("Error getting relative path for %q: %v", childPath, err)
+			continue
+		}
+
+		skip, err := fp.ShouldSkipForResume(checkpoint, relPath)
+		if err != nil {
+			log.Printf("Error checking resume state for %q: %v", relPath, err)
+		} else if skip {
+			continue
+		}
+
+		select {
+		case fileChan <- relPath:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// readDirCached returns dir's children, reusing cache's entry for relDir
+// when dir's ModTime matches and a cheap name-only listing (Readdirnames,
+// which unlike Readdir doesn't stat every child) confirms the same set of
+// names is still present. Otherwise it falls back to a full, stat-backed
+// Readdir and returns reused=false.
+func readDirCached(fs afero.Fs, dir, relDir string, modTime time.Time, cache *WalkCache) ([]WalkCacheEntry, bool, error) {
+	if cached, ok := cache.Dirs[relDir]; ok && cached.ModTime.Equal(modTime) {
+		if names, err := readDirNames(fs, dir); err == nil && sameNames(names, cached.Entries) {
+			return cached.Entries, true, nil
+		}
+	}
+
+	infos, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return nil, false, err
+	}
+	entries := make([]WalkCacheEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = WalkCacheEntry{Name: info.Name(), Size: info.Size(), IsDir: info.IsDir(), ModTime: info.ModTime()}
+	}
+	return entries, false, nil
+}
+
+func readDirNames(fs afero.Fs, dir string) ([]string, error) {
+	f, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// sameNames reports whether names and entries contain the same set of
+// names, ignoring order.
+func sameNames(names []string, entries []WalkCacheEntry) bool {
+	if len(names) != len(entries) {
+		return false
+	}
+	sorted := make([]string, len(entries))
+	for i, e := range entries {
+		sorted[i] = e.Name
+	}
+	sort.Strings(names)
+	sort.Strings(sorted)
+	for i := range names {
+		if names[i] != sorted[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkCache_SaveLoadRoundTrip(t *testing.T) {
+	mr, _, _, fs, _ := setupTestEnvironment(t)
+	defer mr.Close()
+
+	cache := NewWalkCache()
+	cache.Dirs[""] = WalkCacheDir{Entries: []WalkCacheEntry{{Name: "a.bin", Size: 10}}}
+
+	require.NoError(t, SaveWalkCache(fs, "/cache", cache, 1))
+
+	loaded, err := LoadWalkCache(fs, "/cache")
+	require.NoError(t, err)
+	assert.Equal(t, cache.Dirs, loaded.Dirs)
+}
+
+func TestLoadWalkCache_MissingFileIsEmptyCache(t *testing.T) {
+	mr, _, _, fs, _ := setupTestEnvironment(t)
+	defer mr.Close()
+
+	cache, err := LoadWalkCache(fs, "/does-not-exist")
+	require.NoError(t, err)
+	assert.Empty(t, cache.Dirs)
+}
+
+func TestSaveWalkCache_RotatesPreviousToOld(t *testing.T) {
+	mr, _, _, fs, _ := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, SaveWalkCache(fs, "/cache", NewWalkCache(), 1))
+	require.NoError(t, SaveWalkCache(fs, "/cache", NewWalkCache(), 1))
+
+	exists, err := afero.Exists(fs, "/cache.old")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestWalkFilesCached_ReusesUnchangedDirectoryAndFeedsLargeFiles(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, fs.MkdirAll("/root/sub", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/root/sub/big.bin", make([]byte, 1024), 0644))
+
+	fileChan := make(chan string, 10)
+	first, err := walkFilesCached(context.Background(), "/root", 100, fileChan, fp, nil, NewWalkCache())
+	require.NoError(t, err)
+	close(fileChan)
+
+	var got []string
+	for p := range fileChan {
+		got = append(got, p)
+	}
+	assert.Equal(t, []string{"sub/big.bin"}, got)
+
+	fileChan2 := make(chan string, 10)
+	_, err = walkFilesCached(context.Background(), "/root", 100, fileChan2, fp, nil, first)
+	require.NoError(t, err)
+	close(fileChan2)
+
+	var got2 []string
+	for p := range fileChan2 {
+		got2 = append(got2, p)
+	}
+	assert.Equal(t, []string{"sub/big.bin"}, got2)
+}
+
+func TestWalkFilesCached_SkipsFilesBelowMinSize(t *testing.T) {
+	mr, _, _, fs, fp := setupTestEnvironment(t)
+	defer mr.Close()
+
+	require.NoError(t, afero.WriteFile(fs, "/root/small.bin", make([]byte, 10), 0644))
+
+	fileChan := make(chan string, 10)
+	_, err := walkFilesCached(context.Background(), "/root", 100, fileChan, fp, nil, NewWalkCache())
+	require.NoError(t, err)
+	close(fileChan)
+
+	var got []string
+	for p := range fileChan {
+		got = append(got, p)
+	}
+	assert.Empty(t, got)
+}